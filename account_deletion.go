@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DELETE /api/users/me
+// deactivates the authenticated user's account, requires their password as confirmation
+// the account can be restored by logging in until the grace period is over,
+// after that it is hard deleted along with the user's chirps
+func (apiCfg apiConfig) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/users/me")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	type parameters struct {
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("error decoding your json"))
+		return
+	}
+
+	user, err := apiCfg.db.GetUser(userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	// confirm the password before doing anything
+	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(params.Password))
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, errors.New("passwords don't match"))
+		return
+	}
+
+	user, err = apiCfg.db.DeactivateUser(userId, time.Now())
+	if err != nil {
+		respondWithError(w, http.StatusConflict, err)
+		return
+	}
+
+	type retVal struct {
+		Id             int       `json:"id"`
+		Deactivated_at time.Time `json:"deactivated_at"`
+		Delete_after   time.Time `json:"delete_after"`
+	}
+
+	respondWithJSON(w, http.StatusAccepted, retVal{
+		Id:             user.Id,
+		Deactivated_at: *user.Deactivated_at,
+		Delete_after:   user.Deactivated_at.Add(apiCfg.accountDeletionGracePeriod),
+	})
+}
+
+// runs forever in its own goroutine
+// hard deletes the accounts whose deletion grace period is over, checking every interval
+func (apiCfg apiConfig) purgeDeletedAccounts(interval time.Duration) {
+	for {
+		cutoff := time.Now().Add(-apiCfg.accountDeletionGracePeriod)
+		for _, userId := range apiCfg.db.PurgeDeactivatedUsers(cutoff) {
+			log.Printf("deletion grace period over, user %d deleted", userId)
+			apiCfg.removeUserExports(userId)
+		}
+		time.Sleep(interval)
+	}
+}
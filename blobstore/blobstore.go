@@ -0,0 +1,96 @@
+// Package blobstore stores uploaded files, like the images attached to chirps
+package blobstore
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// ErrNotFound is returned when there is no blob with the given key
+var ErrNotFound = errors.New("blob not found")
+
+// keys are generated by the server, anything else is refused so a key can't point outside the store
+var validKey = regexp.MustCompile(`^[a-zA-Z0-9_-]+(\.[a-zA-Z0-9]+)?$`)
+
+// BlobStore stores files by key
+type BlobStore interface {
+	// Put stores everything read from r under key, replacing what was there
+	Put(key string, r io.Reader) error
+	// Open returns the blob stored under key, the caller has to close it
+	Open(key string) (io.ReadSeekCloser, error)
+	// Delete removes the blob stored under key, deleting a missing blob is not an error
+	Delete(key string) error
+}
+
+// DiskStore is a BlobStore keeping every blob as a file in a directory
+type DiskStore struct {
+	dir string
+}
+
+// NewDiskStore returns a store for the directory, creating it if it doesn't exist
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+// Put writes to a temporary file first so a half written blob is never served
+func (store *DiskStore) Put(key string, r io.Reader) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(store.dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (store *DiskStore) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := store.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (store *DiskStore) Delete(key string) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (store *DiskStore) path(key string) (string, error) {
+	if !validKey.MatchString(key) {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(store.dir, key), nil
+}
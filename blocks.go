@@ -0,0 +1,148 @@
+package main
+
+import (
+	"chirpy/database"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// POST /api/users/{id}/block
+// block a user as the authenticated user, also removes any follows between the two of you
+func (apiCfg apiConfig) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/{id}/block")
+	apiCfg.setBlocking(w, r, true)
+}
+
+// DELETE /api/users/{id}/block
+// unblock a user as the authenticated user
+func (apiCfg apiConfig) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/users/{id}/block")
+	apiCfg.setBlocking(w, r, false)
+}
+
+// POST /api/users/{id}/mute
+// mute a user as the authenticated user
+func (apiCfg apiConfig) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/{id}/mute")
+	apiCfg.setMuting(w, r, true)
+}
+
+// DELETE /api/users/{id}/mute
+// unmute a user as the authenticated user
+func (apiCfg apiConfig) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/users/{id}/mute")
+	apiCfg.setMuting(w, r, false)
+}
+
+// used by blockUserHandler and unblockUserHandler
+// responds with the blocked user's id and whether the authenticated user now blocks them
+func (apiCfg apiConfig) setBlocking(w http.ResponseWriter, r *http.Request, blocking bool) {
+	userId, otherId, ok := apiCfg.getRelationParams(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	if blocking {
+		err = apiCfg.db.BlockUser(userId, otherId)
+	} else {
+		err = apiCfg.db.UnblockUser(userId, otherId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	type retVal struct {
+		User_id  int  `json:"user_id"`
+		Blocking bool `json:"blocking"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{User_id: otherId, Blocking: blocking})
+}
+
+// used by muteUserHandler and unmuteUserHandler
+// responds with the muted user's id and whether the authenticated user now mutes them
+func (apiCfg apiConfig) setMuting(w http.ResponseWriter, r *http.Request, muting bool) {
+	userId, otherId, ok := apiCfg.getRelationParams(w, r)
+	if !ok {
+		return
+	}
+
+	var err error
+	if muting {
+		err = apiCfg.db.MuteUser(userId, otherId)
+	} else {
+		err = apiCfg.db.UnmuteUser(userId, otherId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	type retVal struct {
+		User_id int  `json:"user_id"`
+		Muting  bool `json:"muting"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{User_id: otherId, Muting: muting})
+}
+
+// GET /api/users/me/blocks
+// list the users the authenticated user blocked, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readBlocksHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/blocks")
+	apiCfg.respondWithRelationList(w, r, apiCfg.db.GetBlockedUsers)
+}
+
+// GET /api/users/me/mutes
+// list the users the authenticated user muted, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readMutesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/mutes")
+	apiCfg.respondWithRelationList(w, r, apiCfg.db.GetMutedUsers)
+}
+
+// used by readBlocksHandler and readMutesHandler
+// responds with the page of users the given db query returns for the authenticated user
+func (apiCfg apiConfig) respondWithRelationList(w http.ResponseWriter, r *http.Request, query func(userId, limit, offset int) []database.User) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	users := []publicUser{}
+	for _, user := range query(userId, limit, offset) {
+		users = append(users, newPublicUser(user))
+	}
+
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+// used by the block and mute handlers
+// returns the authenticated user's id and the id of the user in the url,
+// responds with an error and returns false if either is missing
+func (apiCfg apiConfig) getRelationParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return 0, 0, false
+	}
+
+	otherId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return 0, 0, false
+	}
+
+	return userId, otherId, true
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// POST /api/chirps/{id}/bookmark
+// save a chirp to the authenticated user's bookmarks, bookmarking a chirp twice does nothing
+func (apiCfg apiConfig) bookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/chirps/{id}/bookmark")
+	apiCfg.setChirpBookmarked(w, r, true)
+}
+
+// DELETE /api/chirps/{id}/bookmark
+// remove a chirp from the authenticated user's bookmarks
+func (apiCfg apiConfig) unbookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/chirps/{id}/bookmark")
+	apiCfg.setChirpBookmarked(w, r, false)
+}
+
+// used by bookmarkChirpHandler and unbookmarkChirpHandler
+// responds with the chirp id and whether the user now has it bookmarked
+func (apiCfg apiConfig) setChirpBookmarked(w http.ResponseWriter, r *http.Request, bookmarked bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	if bookmarked {
+		err = apiCfg.db.BookmarkChirp(userId, chirpId)
+	} else {
+		err = apiCfg.db.RemoveBookmark(userId, chirpId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	type retVal struct {
+		Chirp_id   int  `json:"chirp_id"`
+		Bookmarked bool `json:"bookmarked"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{Chirp_id: chirpId, Bookmarked: bookmarked})
+}
+
+// GET /api/users/me/bookmarks
+// the chirps the authenticated user bookmarked, most recently bookmarked first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/bookmarks")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	chirps := apiCfg.db.GetBookmarkedChirps(userId, limit, offset)
+	respondWithJSON(w, http.StatusOK, apiCfg.newChirpResponses(chirps, userId))
+}
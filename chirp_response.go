@@ -0,0 +1,104 @@
+package main
+
+import (
+	"chirpy/database"
+	"net/http"
+	"strconv"
+)
+
+// chirpResponse is how chirps are sent back to clients
+// the stored chirp plus everything that depends on who is looking at it
+type chirpResponse struct {
+	database.Chirp
+	Like_count    int  `json:"like_count"`
+	Liked         bool `json:"liked"`
+	Rechirp_count int  `json:"rechirp_count"`
+	Rechirped     bool `json:"rechirped"`
+	Reply_count   int  `json:"reply_count"`
+	// bookmarks are private, this is only ever true for the viewer's own
+	Bookmarked bool `json:"bookmarked"`
+	// whether the author pinned it to their profile
+	Pinned bool `json:"pinned"`
+	// the chirp being quoted, for quote chirps
+	// Quote_unavailable is set instead when the quoted chirp was deleted or the viewer can't see it
+	Quoted_chirp      *database.Chirp `json:"quoted_chirp,omitempty"`
+	Quote_unavailable bool            `json:"quote_unavailable,omitempty"`
+	// the images in Attachment_ids, with where to get them
+	Attachments []mediaResponse `json:"attachments,omitempty"`
+	// replaces the stored poll, with the results if the viewer can see them
+	Poll *pollResponse `json:"poll,omitempty"`
+	// previews of the links in the chirp, once they have been fetched
+	Link_previews []database.LinkPreview `json:"link_previews,omitempty"`
+}
+
+// builds the response for a single chirp as seen by the viewer
+// viewerId 0 means an anonymous viewer
+func (apiCfg apiConfig) newChirpResponse(chirp database.Chirp, viewerId int) chirpResponse {
+	likeCount, liked := apiCfg.db.GetLikeInfo(chirp.Id, viewerId)
+	rechirpCount, rechirped := apiCfg.db.GetRechirpInfo(chirp.Id, viewerId)
+	response := chirpResponse{
+		Chirp:         chirp,
+		Like_count:    likeCount,
+		Liked:         liked,
+		Rechirp_count: rechirpCount,
+		Rechirped:     rechirped,
+		Reply_count:   apiCfg.db.GetReplyCount(chirp.Id),
+		Bookmarked:    apiCfg.db.IsBookmarked(chirp.Id, viewerId),
+		Pinned:        apiCfg.db.IsPinned(chirp),
+		Attachments:   apiCfg.chirpAttachments(chirp),
+		Poll:          apiCfg.newPollResponse(chirp, viewerId),
+		Link_previews: apiCfg.db.GetLinkPreviews(chirp),
+	}
+
+	if chirp.Quote_of != 0 {
+		quoted, err := apiCfg.db.GetChirp(chirp.Quote_of)
+		if err != nil || !apiCfg.db.CanSeeChirp(quoted, viewerId) {
+			response.Quote_unavailable = true
+		} else {
+			response.Quoted_chirp = &quoted
+		}
+	}
+
+	return response
+}
+
+// builds the responses for a list of chirps as seen by the viewer
+func (apiCfg apiConfig) newChirpResponses(chirps []database.Chirp, viewerId int) []chirpResponse {
+	responses := make([]chirpResponse, 0, len(chirps))
+	for _, chirp := range chirps {
+		responses = append(responses, apiCfg.newChirpResponse(chirp, viewerId))
+	}
+	return responses
+}
+
+// used by endpoints that work with or without authentication
+// returns the id of the authenticated user, or 0 if there isn't a valid access token
+func (apiCfg apiConfig) getOptionalUserId(r *http.Request) int {
+	if r.Header.Get("Authorization") == "" {
+		return 0
+	}
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		return 0
+	}
+	return userId
+}
+
+// reads the optional `limit` and `offset` query parameters used by paginated endpoints
+// limit defaults to 20 and is capped at 100, offset defaults to 0
+func getPaginationParams(r *http.Request) (int, int) {
+	limit := 20
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 {
+		limit = parsed
+	}
+	if limit > 100 {
+		limit = 100
+	}
+
+	offset := 0
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("offset")); err == nil && parsed > 0 {
+		offset = parsed
+	}
+
+	return limit, offset
+}
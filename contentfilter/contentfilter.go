@@ -0,0 +1,340 @@
+// Package contentfilter checks user written text against a community's rules
+// rules are word lists and regular expressions, run in order as a pipeline,
+// and each one either replaces what it matches, rejects the text, or flags it for review
+package contentfilter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// kinds of content a rule can apply to
+const (
+	KindChirp   = "chirp"
+	KindBio     = "bio"
+	KindMessage = "message"
+)
+
+// what a rule does with a match
+const (
+	ActionReplace = "replace"
+	ActionReject  = "reject"
+	ActionFlag    = "flag"
+)
+
+// types of rules in a config file
+const (
+	RuleWords = "words"
+	RuleRegex = "regex"
+)
+
+// what matches are replaced with when a replace rule doesn't say
+const defaultReplacement = "****"
+
+// ContentFilter checks a piece of text of the given kind
+type ContentFilter interface {
+	Filter(kind, text string) Result
+}
+
+// Result is the outcome of filtering a piece of text
+type Result struct {
+	// the text with every replacement made
+	Text string
+	// set when a reject rule matched, the text must not be stored
+	Rejected bool
+	// name of the rule that rejected the text
+	Rejected_by string
+	// names of the flag rules that matched, the text is fine to store but a moderator should look at it
+	Flagged_by []string
+}
+
+// Config is the contents of a content filter config file
+type Config struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+// RuleConfig is a single rule in a config file
+type RuleConfig struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // words or regex
+	// for words rules, single words or phrases, matched ignoring case and surrounding punctuation
+	Words []string `json:"words,omitempty"`
+	// for regex rules, RE2 syntax, use (?i) to ignore case
+	Pattern     string `json:"pattern,omitempty"`
+	Action      string `json:"action"` // replace, reject or flag
+	Replacement string `json:"replacement,omitempty"`
+	// kinds of content the rule applies to, all of them if left out
+	Applies_to []string `json:"applies_to,omitempty"`
+}
+
+// DefaultConfig is used when there is no config file, it censors the words Chirpy always censored
+var DefaultConfig = Config{
+	Rules: []RuleConfig{{
+		Name:   "profanity",
+		Type:   RuleWords,
+		Words:  []string{"kerfuffle", "sharbert", "fornax"},
+		Action: ActionReplace,
+	}},
+}
+
+// Pipeline runs its rules in order, each one sees the text as the ones before it left it
+// it stops at the first rule that rejects the text
+type Pipeline struct {
+	rules []rule
+}
+
+// a single compiled rule
+type rule struct {
+	name        string
+	action      string
+	replacement string
+	kinds       map[string]bool // nil means every kind
+	matcher     matcher
+}
+
+// finds what a rule matches in a text
+type matcher interface {
+	// returns the byte ranges of the matches, in order and not overlapping
+	find(text string) [][2]int
+	// replaces every match
+	replace(text, replacement string) string
+}
+
+// Filter runs the text through every rule that applies to its kind
+func (pipeline *Pipeline) Filter(kind, text string) Result {
+	result := Result{Text: text}
+	for _, rule := range pipeline.rules {
+		if rule.kinds != nil && !rule.kinds[kind] {
+			continue
+		}
+		if len(rule.matcher.find(result.Text)) == 0 {
+			continue
+		}
+		switch rule.action {
+		case ActionReplace:
+			result.Text = rule.matcher.replace(result.Text, rule.replacement)
+		case ActionReject:
+			result.Rejected = true
+			result.Rejected_by = rule.name
+			return result
+		case ActionFlag:
+			result.Flagged_by = append(result.Flagged_by, rule.name)
+		}
+	}
+	return result
+}
+
+// NewPipeline compiles the rules of a config
+func NewPipeline(config Config) (*Pipeline, error) {
+	pipeline := &Pipeline{}
+	for i, ruleConfig := range config.Rules {
+		name := ruleConfig.Name
+		if name == "" {
+			name = fmt.Sprintf("rule %d", i+1)
+		}
+
+		compiled := rule{name: name, action: ruleConfig.Action, replacement: ruleConfig.Replacement}
+		switch ruleConfig.Action {
+		case ActionReplace:
+			if compiled.replacement == "" {
+				compiled.replacement = defaultReplacement
+			}
+		case ActionReject, ActionFlag:
+		default:
+			return nil, fmt.Errorf("%s: action must be replace, reject or flag", name)
+		}
+
+		if len(ruleConfig.Applies_to) > 0 {
+			compiled.kinds = map[string]bool{}
+			for _, kind := range ruleConfig.Applies_to {
+				if kind != KindChirp && kind != KindBio && kind != KindMessage {
+					return nil, fmt.Errorf("%s: applies_to can only contain chirp, bio and message", name)
+				}
+				compiled.kinds[kind] = true
+			}
+		}
+
+		switch ruleConfig.Type {
+		case RuleWords:
+			if len(ruleConfig.Words) == 0 {
+				return nil, fmt.Errorf("%s: words rule without any words", name)
+			}
+			compiled.matcher = newWordMatcher(ruleConfig.Words)
+		case RuleRegex:
+			if ruleConfig.Pattern == "" {
+				return nil, fmt.Errorf("%s: regex rule without a pattern", name)
+			}
+			pattern, err := regexp.Compile(ruleConfig.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			compiled.matcher = regexMatcher{pattern: pattern}
+		default:
+			return nil, fmt.Errorf("%s: type must be words or regex", name)
+		}
+
+		pipeline.rules = append(pipeline.rules, compiled)
+	}
+	return pipeline, nil
+}
+
+// LoadPipeline reads a config file and compiles its rules
+func LoadPipeline(path string) (*Pipeline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := Config{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	pipeline, err := NewPipeline(config)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pipeline, nil
+}
+
+// Default returns the pipeline for DefaultConfig
+func Default() *Pipeline {
+	pipeline, err := NewPipeline(DefaultConfig)
+	if err != nil {
+		panic(err)
+	}
+	return pipeline
+}
+
+// matches regular expressions
+type regexMatcher struct {
+	pattern *regexp.Regexp
+}
+
+func (m regexMatcher) find(text string) [][2]int {
+	matches := [][2]int{}
+	for _, match := range m.pattern.FindAllStringIndex(text, -1) {
+		matches = append(matches, [2]int{match[0], match[1]})
+	}
+	return matches
+}
+
+// the replacement can refer to capture groups, like $1
+func (m regexMatcher) replace(text, replacement string) string {
+	return m.pattern.ReplaceAllString(text, replacement)
+}
+
+// matches whole words and phrases, ignoring case and the punctuation around them
+// "Fornax!" and "fornax," match fornax, "fornaxes" doesn't
+type wordMatcher struct {
+	// lower cased phrases, split into words
+	phrases [][]string
+}
+
+func newWordMatcher(words []string) wordMatcher {
+	m := wordMatcher{}
+	for _, word := range words {
+		tokens := tokenize(word)
+		if len(tokens) == 0 {
+			continue
+		}
+		phrase := []string{}
+		for _, t := range tokens {
+			phrase = append(phrase, t.word)
+		}
+		m.phrases = append(m.phrases, phrase)
+	}
+	return m
+}
+
+func (m wordMatcher) find(text string) [][2]int {
+	tokens := tokenize(text)
+	matches := [][2]int{}
+	for i := 0; i < len(tokens); {
+		length := m.matchAt(tokens, i)
+		if length == 0 {
+			i++
+			continue
+		}
+		matches = append(matches, [2]int{tokens[i].start, tokens[i+length-1].end})
+		i += length
+	}
+	return matches
+}
+
+func (m wordMatcher) replace(text, replacement string) string {
+	var builder strings.Builder
+	last := 0
+	for _, match := range m.find(text) {
+		builder.WriteString(text[last:match[0]])
+		builder.WriteString(replacement)
+		last = match[1]
+	}
+	builder.WriteString(text[last:])
+	return builder.String()
+}
+
+// returns how many tokens the longest phrase matching at tokens[i] covers, 0 if none matches
+func (m wordMatcher) matchAt(tokens []token, i int) int {
+	longest := 0
+	for _, phrase := range m.phrases {
+		if len(phrase) <= longest || i+len(phrase) > len(tokens) {
+			continue
+		}
+		matched := true
+		for j, word := range phrase {
+			if tokens[i+j].word != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			longest = len(phrase)
+		}
+	}
+	return longest
+}
+
+// a word in a text, lower cased, with its byte range in the text
+type token struct {
+	word       string
+	start, end int
+}
+
+// splits a text into words: runs of letters, digits and combining marks in any script
+// everything else (spaces, punctuation, emoji) separates words
+func tokenize(text string) []token {
+	tokens := []token{}
+	start := -1
+	for i, r := range text {
+		if isWordRune(r) {
+			if start == -1 {
+				start = i
+			}
+			continue
+		}
+		if start != -1 {
+			tokens = append(tokens, token{word: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start != -1 {
+		tokens = append(tokens, token{word: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Mc, r)
+}
+
+// ErrRejected is wrapped by the errors returned for content a reject rule matched
+var ErrRejected = errors.New("rejected by the content filter")
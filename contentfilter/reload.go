@@ -0,0 +1,107 @@
+package contentfilter
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader is a ContentFilter backed by a config file, it picks up changes to the file without a restart
+// while the file doesn't exist DefaultConfig is used, if a changed file has errors the rules it had before are kept
+type Reloader struct {
+	path     string
+	mux      *sync.RWMutex
+	pipeline *Pipeline
+	modTime  time.Time
+	exists   bool
+}
+
+// NewReloader loads the config file at path
+// a missing file is fine, a file with errors isn't
+func NewReloader(path string) (*Reloader, error) {
+	reloader := &Reloader{
+		path:     path,
+		mux:      &sync.RWMutex{},
+		pipeline: Default(),
+	}
+	if _, err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Filter runs the text through the current rules
+func (reloader *Reloader) Filter(kind, text string) Result {
+	reloader.mux.RLock()
+	pipeline := reloader.pipeline
+	reloader.mux.RUnlock()
+
+	return pipeline.Filter(kind, text)
+}
+
+// Reload loads the config file again if it changed since it was last loaded
+// returns whether the rules changed
+func (reloader *Reloader) Reload() (bool, error) {
+	info, err := os.Stat(reloader.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		reloader.mux.Lock()
+		defer reloader.mux.Unlock()
+		if !reloader.exists {
+			return false, nil
+		}
+		// the file was removed, back to the defaults
+		reloader.pipeline = Default()
+		reloader.exists = false
+		reloader.modTime = time.Time{}
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	reloader.mux.RLock()
+	unchanged := reloader.exists && info.ModTime().Equal(reloader.modTime)
+	reloader.mux.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	pipeline, err := LoadPipeline(reloader.path)
+
+	reloader.mux.Lock()
+	defer reloader.mux.Unlock()
+	// a broken file is only reported once, not on every check until it's fixed
+	reloader.exists = true
+	reloader.modTime = info.ModTime()
+	if err != nil {
+		return false, err
+	}
+	reloader.pipeline = pipeline
+	return true, nil
+}
+
+// Watch checks the config file for changes every interval until stop is closed
+// meant to be run as a goroutine
+func (reloader *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			changed, err := reloader.Reload()
+			if err != nil {
+				log.Printf("content filter: keeping the current rules, %v", err)
+				continue
+			}
+			if changed {
+				log.Printf("content filter: reloaded %s", reloader.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"archive/zip"
+	"chirpy/database"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// index page included in every data export, so the archive is readable without any tools
+var exportIndexTemplate = template.Must(template.New("index").Parse(`<html>
+
+<body>
+    <h1>Chirpy data export</h1>
+    <p>Generated {{.Generated_at.Format "2006-01-02 15:04:05 MST"}}. The complete data is in <code>data.json</code>.</p>
+
+    <h2>Profile</h2>
+    <ul>
+        <li>id: {{.Profile.Id}}</li>
+        <li>email: {{.Profile.Email}}</li>
+        <li>Chirpy Red: {{.Profile.Is_chirpy_red}}</li>
+    </ul>
+
+    <h2>Chirps ({{len .Chirps}})</h2>
+    <ul>
+    {{- range .Chirps}}
+        <li>#{{.Id}}: {{.Body}}</li>
+    {{- end}}
+    </ul>
+
+    <h2>Images ({{len .Media}})</h2>
+    {{- range .Media}}
+    <img src="media/{{.Key}}" width="200" alt="image {{.Id}}">
+    {{- end}}
+</body>
+
+</html>
+`))
+
+// finished archives are removed this long after they were generated
+const exportLifetime = 7 * 24 * time.Hour
+
+// exportResponse is an export job as shown to its user, without where its archive is stored
+type exportResponse struct {
+	Id           int        `json:"id"`
+	User_id      int        `json:"user_id"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	Completed_at *time.Time `json:"completed_at,omitempty"`
+	// when a finished archive is removed
+	Expires_at *time.Time `json:"expires_at,omitempty"`
+}
+
+func newExportResponse(export database.Export) exportResponse {
+	response := exportResponse{
+		Id:           export.Id,
+		User_id:      export.User_id,
+		Status:       export.Status,
+		Error:        export.Error,
+		Created_at:   export.Created_at,
+		Completed_at: export.Completed_at,
+	}
+	if export.Completed_at != nil {
+		expiresAt := export.Completed_at.Add(exportLifetime)
+		response.Expires_at = &expiresAt
+	}
+	return response
+}
+
+// POST /api/users/me/exports
+// starts generating an archive of all the authenticated user's data in the background
+// poll GET /api/users/me/exports/{id} for its status
+func (apiCfg apiConfig) createExportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/me/exports")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	export, err := apiCfg.db.CreateExport(userId)
+	if err != nil {
+		respondWithError(w, http.StatusConflict, err)
+		return
+	}
+
+	go apiCfg.runExport(export)
+
+	respondWithJSON(w, http.StatusAccepted, newExportResponse(export))
+}
+
+// GET /api/users/me/exports/{id}
+// returns the status of one of the authenticated user's exports
+func (apiCfg apiConfig) readExportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/exports/{id}")
+	export, ok := apiCfg.getOwnExport(w, r)
+	if !ok {
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, newExportResponse(export))
+}
+
+// GET /api/users/me/exports/{id}/download
+// sends the finished archive as a zip file
+func (apiCfg apiConfig) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/exports/{id}/download")
+	export, ok := apiCfg.getOwnExport(w, r)
+	if !ok {
+		return
+	}
+
+	if export.Status != database.ExportComplete {
+		respondWithError(w, http.StatusConflict, fmt.Errorf("export is %s", export.Status))
+		return
+	}
+
+	// the archive may have been removed from disk, never serve anything else in its place
+	path := apiCfg.exportPath(export)
+	if info, err := os.Stat(path); export.File == "" || err != nil || !info.Mode().IsRegular() {
+		respondWithError(w, http.StatusNotFound, errors.New("the archive of this export is gone, start a new export"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%d.zip"`, export.Id))
+	http.ServeFile(w, r, path)
+}
+
+// where the archive of an export is stored
+func (apiCfg apiConfig) exportPath(export database.Export) string {
+	return filepath.Join(apiCfg.exportDir, strconv.Itoa(export.User_id), filepath.Base(export.File))
+}
+
+// used by the export handlers
+// looks up the export in the url and makes sure it belongs to the authenticated user,
+// responds with an error and returns false otherwise
+func (apiCfg apiConfig) getOwnExport(w http.ResponseWriter, r *http.Request) (database.Export, bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return database.Export{}, false
+	}
+
+	exportId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return database.Export{}, false
+	}
+
+	// other users' exports are reported as missing rather than forbidden
+	export, err := apiCfg.db.GetExport(exportId)
+	if err != nil || export.User_id != userId {
+		respondWithError(w, http.StatusNotFound, fmt.Errorf("export with ID %d not found", exportId))
+		return database.Export{}, false
+	}
+
+	return export, true
+}
+
+// generates the archive for an export job and records the outcome
+// meant to run in its own goroutine
+func (apiCfg apiConfig) runExport(export database.Export) {
+	export.Status = database.ExportRunning
+	apiCfg.db.UpdateExport(export)
+
+	file, err := apiCfg.writeExportArchive(export)
+	now := time.Now()
+	export.Completed_at = &now
+	if err != nil {
+		log.Printf("export %d failed: %v", export.Id, err)
+		export.Status = database.ExportFailed
+		export.Error = "could not generate the archive"
+	} else {
+		export.Status = database.ExportComplete
+		export.File = file
+	}
+
+	if err := apiCfg.db.UpdateExport(export); err != nil && file != "" {
+		// the user was deleted while the archive was being written
+		os.Remove(apiCfg.exportPath(export))
+	}
+}
+
+// writes the archive for the export's user into a zip file
+// archives live in <exportDir>/<user id>/ so they can be removed along with the user,
+// under random names so they can't be guessed from the ids
+// a half written archive is removed again
+// returns the name of the zip file
+func (apiCfg apiConfig) writeExportArchive(export database.Export) (string, error) {
+	archive, err := apiCfg.db.ExportUserData(export.User_id)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(apiCfg.exportDir, strconv.Itoa(export.User_id))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("export-%s.zip", hex.EncodeToString(random))
+	path := filepath.Join(dir, name)
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return "", err
+	}
+	err = apiCfg.writeExportZip(file, archive)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return "", err
+	}
+
+	return name, nil
+}
+
+// used by writeExportArchive
+// writes data.json, index.html and the uploaded images into the zip file
+func (apiCfg apiConfig) writeExportZip(file *os.File, archive database.UserArchive) error {
+	zipWriter := zip.NewWriter(file)
+
+	dataFile, err := zipWriter.Create("data.json")
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(dataFile)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(archive); err != nil {
+		return err
+	}
+
+	indexFile, err := zipWriter.Create("index.html")
+	if err != nil {
+		return err
+	}
+	if err := exportIndexTemplate.Execute(indexFile, archive); err != nil {
+		return err
+	}
+
+	// the uploaded images themselves, without their thumbnails
+	for _, media := range archive.Media {
+		mediaFile, err := zipWriter.Create("media/" + media.Key)
+		if err != nil {
+			return err
+		}
+		if err := copyBlob(apiCfg.blobs, media.Key, mediaFile); err != nil {
+			return err
+		}
+	}
+
+	return zipWriter.Close()
+}
+
+// picks up the exports that were interrupted by a restart
+func (apiCfg apiConfig) resumeExports() {
+	for _, export := range apiCfg.db.GetUnfinishedExports() {
+		go apiCfg.runExport(export)
+	}
+}
+
+// removes finished exports and their archives once they are older than exportLifetime, every interval
+// meant to be run as a goroutine
+func (apiCfg apiConfig) purgeExpiredExports(interval time.Duration) {
+	for {
+		for _, export := range apiCfg.db.PurgeExpiredExports(time.Now().Add(-exportLifetime)) {
+			if export.File == "" {
+				continue
+			}
+			if err := os.Remove(apiCfg.exportPath(export)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				log.Println(err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// removes the archives of a deleted user from disk
+func (apiCfg apiConfig) removeUserExports(userId int) {
+	if err := os.RemoveAll(filepath.Join(apiCfg.exportDir, strconv.Itoa(userId))); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,181 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// isUserDeactivated checks if a user has a pending account deletion
+// caller must hold a Reader or Writer lock
+func (db *DB) isUserDeactivated(userId int) bool {
+	user, ok := db.dbstruct.Users[userId]
+	return ok && user.IsDeactivated()
+}
+
+// DeactivateUser marks a user's account for deletion
+// the user and their chirps stay stored (hidden) until PurgeDeactivatedUsers removes them
+func (db *DB) DeactivateUser(userId int, at time.Time) (User, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return User{}, errors.New("user not found")
+	}
+	if user.IsDeactivated() {
+		return user, errors.New("user is already deactivated")
+	}
+
+	user.Deactivated_at = &at
+	db.dbstruct.Users[userId] = user
+	db.writeDB()
+
+	return user, nil
+}
+
+// ReactivateUser cancels a pending account deletion
+func (db *DB) ReactivateUser(userId int) (User, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return User{}, errors.New("user not found")
+	}
+
+	user.Deactivated_at = nil
+	db.dbstruct.Users[userId] = user
+	db.writeDB()
+
+	return user, nil
+}
+
+// DeleteUser hard deletes a user along with everything stored for them
+func (db *DB) DeleteUser(userId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok {
+		return errors.New("user not found")
+	}
+
+	db.deleteUser(userId)
+	db.writeDB()
+
+	return nil
+}
+
+// PurgeDeactivatedUsers hard deletes every user that was deactivated before the cutoff
+// returns the ids of the deleted users
+func (db *DB) PurgeDeactivatedUsers(cutoff time.Time) []int {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	purged := []int{}
+	for id, user := range db.dbstruct.Users {
+		if user.IsDeactivated() && user.Deactivated_at.Before(cutoff) {
+			db.deleteUser(id)
+			purged = append(purged, id)
+		}
+	}
+
+	if len(purged) > 0 {
+		db.writeDB()
+	}
+
+	return purged
+}
+
+// deleteUser removes a user and cascades to everything that belongs to them
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) deleteUser(userId int) {
+	for id, chirp := range db.dbstruct.Chirps {
+		if chirp.Author_id == userId {
+			db.deleteChirp(id)
+		}
+	}
+
+	for chirpId := range db.likesByUser[userId] {
+		db.removeLike(userId, chirpId)
+	}
+
+	for followeeId := range db.dbstruct.Follows[userId] {
+		db.removeFollow(userId, followeeId)
+	}
+	for followerId := range db.followersOf[userId] {
+		db.removeFollow(followerId, userId)
+	}
+	delete(db.dbstruct.FollowRequests, userId)
+	for requesterId := range db.dbstruct.FollowRequests {
+		db.removeFollowRequest(requesterId, userId)
+	}
+
+	for id, rechirp := range db.dbstruct.Rechirps {
+		if rechirp.User_id == userId {
+			db.removeRechirp(id)
+		}
+	}
+
+	for id, export := range db.dbstruct.Exports {
+		if export.User_id == userId {
+			delete(db.dbstruct.Exports, id)
+		}
+	}
+
+	for id, notification := range db.dbstruct.Notifications {
+		if notification.User_id == userId || notification.Actor_id == userId {
+			db.removeNotification(id)
+		}
+	}
+	delete(db.dbstruct.NotificationPreferences, userId)
+
+	db.removeFromConversations(userId)
+	db.removeBlocksAndMutes(userId)
+
+	db.removeVotesBy(userId)
+
+	for id, draft := range db.dbstruct.Drafts {
+		if draft.Author_id == userId {
+			delete(db.dbstruct.Drafts, id)
+		}
+	}
+
+	delete(db.dbstruct.Bookmarks, userId)
+	for id, list := range db.dbstruct.Lists {
+		if list.Owner_id == userId {
+			db.removeList(id)
+		}
+	}
+	for listId := range db.dbstruct.ListMembers {
+		db.removeListMember(listId, userId)
+	}
+
+	delete(db.dbstruct.DismissedRecommendations, userId)
+	for id := range db.dbstruct.DismissedRecommendations {
+		removeRelation(db.dbstruct.DismissedRecommendations, id, userId)
+	}
+
+	// attached media went with the chirps, this is what was never attached
+	for id, media := range db.dbstruct.Media {
+		if media.Owner_id == userId {
+			db.removeMedia(id)
+		}
+	}
+
+	// reports about the user and the moderation audit trail are kept
+	for id, report := range db.dbstruct.Reports {
+		if report.Reporter_id == userId {
+			delete(db.dbstruct.Reports, id)
+		}
+	}
+
+	if handle := db.dbstruct.Users[userId].Handle; handle != "" {
+		delete(db.usersByHandle, strings.ToLower(handle))
+	}
+	delete(db.dbstruct.Users, userId)
+}
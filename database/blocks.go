@@ -0,0 +1,228 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// returned when a user tries to interact with someone they blocked or were blocked by
+var ErrBlocked = errors.New("you can't interact with this user")
+
+// Block is one user blocking another
+// blocks work both ways: neither user sees the other's chirps or can follow, reply to, mention or message them
+type Block struct {
+	Blocker_id int       `json:"blocker_id"`
+	Blocked_id int       `json:"blocked_id"`
+	Since      time.Time `json:"since"`
+}
+
+// Mute is one user muting another
+// only hides the muted user from the muter's timeline and notifications, the muted user doesn't notice
+type Mute struct {
+	Muter_id int       `json:"muter_id"`
+	Muted_id int       `json:"muted_id"`
+	Since    time.Time `json:"since"`
+}
+
+// BlockUser makes the blocker block the blocked user, blocking someone twice does nothing
+// any follows between the two users are removed
+func (db *DB) BlockUser(blockerId, blockedId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if blockerId == blockedId {
+		return errors.New("you can't block yourself")
+	}
+	if _, ok := db.dbstruct.Users[blockedId]; !ok {
+		return fmt.Errorf("user with ID %d not found", blockedId)
+	}
+
+	if _, ok := db.dbstruct.Blocks[blockerId][blockedId]; ok {
+		return nil
+	}
+
+	if db.dbstruct.Blocks[blockerId] == nil {
+		db.dbstruct.Blocks[blockerId] = make(map[int]time.Time)
+	}
+	db.dbstruct.Blocks[blockerId][blockedId] = time.Now()
+
+	db.removeFollow(blockerId, blockedId)
+	db.removeFollow(blockedId, blockerId)
+	db.removeFollowRequest(blockerId, blockedId)
+	db.removeFollowRequest(blockedId, blockerId)
+	db.writeDB()
+
+	return nil
+}
+
+// UnblockUser removes a block, if there was one
+// follows removed by the block don't come back
+func (db *DB) UnblockUser(blockerId, blockedId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[blockedId]; !ok {
+		return fmt.Errorf("user with ID %d not found", blockedId)
+	}
+
+	if _, ok := db.dbstruct.Blocks[blockerId][blockedId]; ok {
+		removeRelation(db.dbstruct.Blocks, blockerId, blockedId)
+		db.writeDB()
+	}
+
+	return nil
+}
+
+// MuteUser makes the muter mute the muted user, muting someone twice does nothing
+func (db *DB) MuteUser(muterId, mutedId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if muterId == mutedId {
+		return errors.New("you can't mute yourself")
+	}
+	if _, ok := db.dbstruct.Users[mutedId]; !ok {
+		return fmt.Errorf("user with ID %d not found", mutedId)
+	}
+
+	if _, ok := db.dbstruct.Mutes[muterId][mutedId]; ok {
+		return nil
+	}
+
+	if db.dbstruct.Mutes[muterId] == nil {
+		db.dbstruct.Mutes[muterId] = make(map[int]time.Time)
+	}
+	db.dbstruct.Mutes[muterId][mutedId] = time.Now()
+	db.writeDB()
+
+	return nil
+}
+
+// UnmuteUser removes a mute, if there was one
+func (db *DB) UnmuteUser(muterId, mutedId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[mutedId]; !ok {
+		return fmt.Errorf("user with ID %d not found", mutedId)
+	}
+
+	if _, ok := db.dbstruct.Mutes[muterId][mutedId]; ok {
+		removeRelation(db.dbstruct.Mutes, muterId, mutedId)
+		db.writeDB()
+	}
+
+	return nil
+}
+
+// GetBlockedUsers returns the users a user blocked, most recent block first
+func (db *DB) GetBlockedUsers(userId, limit, offset int) []User {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.usersFromRelationMap(db.dbstruct.Blocks[userId], limit, offset)
+}
+
+// GetMutedUsers returns the users a user muted, most recent mute first
+func (db *DB) GetMutedUsers(userId, limit, offset int) []User {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.usersFromRelationMap(db.dbstruct.Mutes[userId], limit, offset)
+}
+
+// IsBlocked checks if either of two users blocked the other
+func (db *DB) IsBlocked(userId, otherId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.isBlocked(userId, otherId)
+}
+
+// IsMuted checks if the muter muted the other user
+func (db *DB) IsMuted(muterId, mutedId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.isMuted(muterId, mutedId)
+}
+
+// isBlocked checks if either of two users blocked the other
+// caller must hold a Reader or Writer lock
+func (db *DB) isBlocked(userId, otherId int) bool {
+	if _, ok := db.dbstruct.Blocks[userId][otherId]; ok {
+		return true
+	}
+	_, ok := db.dbstruct.Blocks[otherId][userId]
+	return ok
+}
+
+// caller must hold a Reader or Writer lock
+func (db *DB) isMuted(muterId, mutedId int) bool {
+	_, ok := db.dbstruct.Mutes[muterId][mutedId]
+	return ok
+}
+
+// isHiddenFrom checks if a user's content is hidden from the viewer,
+// because the user is deactivated or one of them blocked the other
+// viewerId 0 means an anonymous viewer
+// caller must hold a Reader or Writer lock
+func (db *DB) isHiddenFrom(userId, viewerId int) bool {
+	return db.isUserDeactivated(userId) || db.isBlocked(userId, viewerId)
+}
+
+// isMutedOrHidden checks if a user's content is kept off the viewer's timeline and notifications,
+// on top of isHiddenFrom that also covers users the viewer muted
+// caller must hold a Reader or Writer lock
+func (db *DB) isMutedOrHidden(userId, viewerId int) bool {
+	return db.isHiddenFrom(userId, viewerId) || db.isMuted(viewerId, userId)
+}
+
+// turns a user id -> since map into a page of users, most recent first
+// caller must hold a Reader or Writer lock
+func (db *DB) usersFromRelationMap(relations map[int]time.Time, limit, offset int) []User {
+	ids := []int{}
+	for id := range relations {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return relations[ids[i]].After(relations[ids[j]])
+	})
+
+	users := []User{}
+	for _, id := range paginate(ids, limit, offset) {
+		users = append(users, db.dbstruct.Users[id])
+	}
+	return users
+}
+
+// removeBlocksAndMutes removes every block and mute from or of a deleted user
+// caller must hold the Writer lock
+func (db *DB) removeBlocksAndMutes(userId int) {
+	delete(db.dbstruct.Blocks, userId)
+	delete(db.dbstruct.Mutes, userId)
+	for id := range db.dbstruct.Blocks {
+		removeRelation(db.dbstruct.Blocks, id, userId)
+	}
+	for id := range db.dbstruct.Mutes {
+		removeRelation(db.dbstruct.Mutes, id, userId)
+	}
+}
+
+// deletes one entry from a user id -> user id -> since map, and the inner map once it's empty
+func removeRelation(relations map[int]map[int]time.Time, userId, otherId int) {
+	delete(relations[userId], otherId)
+	if len(relations[userId]) == 0 {
+		delete(relations, userId)
+	}
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Bookmark is a chirp a user saved for later, only they can see their bookmarks
+type Bookmark struct {
+	User_id       int       `json:"user_id"`
+	Chirp_id      int       `json:"chirp_id"`
+	Bookmarked_at time.Time `json:"bookmarked_at"`
+}
+
+// BookmarkChirp saves a chirp to a user's bookmarks, bookmarking a chirp twice does nothing
+func (db *DB) BookmarkChirp(userId, chirpId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, userId) {
+		return fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	if _, ok := db.dbstruct.Bookmarks[userId][chirpId]; ok {
+		return nil
+	}
+
+	if db.dbstruct.Bookmarks[userId] == nil {
+		db.dbstruct.Bookmarks[userId] = make(map[int]time.Time)
+	}
+	db.dbstruct.Bookmarks[userId][chirpId] = time.Now()
+	db.writeDB()
+
+	return nil
+}
+
+// RemoveBookmark removes a chirp from a user's bookmarks, if it is there
+func (db *DB) RemoveBookmark(userId, chirpId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Chirps[chirpId]; !ok {
+		return fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	if _, ok := db.dbstruct.Bookmarks[userId][chirpId]; ok {
+		db.removeBookmark(userId, chirpId)
+		db.writeDB()
+	}
+
+	return nil
+}
+
+// IsBookmarked checks if a user bookmarked a chirp
+// viewerId 0 means an anonymous viewer, who has no bookmarks
+func (db *DB) IsBookmarked(chirpId, viewerId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, ok := db.dbstruct.Bookmarks[viewerId][chirpId]
+	return ok
+}
+
+// GetBookmarkedChirps returns the chirps a user bookmarked that they can still see, most recently bookmarked first
+func (db *DB) GetBookmarkedChirps(userId, limit, offset int) []Chirp {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	bookmarks := []Bookmark{}
+	for _, bookmark := range db.bookmarksOf(userId) {
+		if !db.isChirpHiddenFrom(db.dbstruct.Chirps[bookmark.Chirp_id], userId) {
+			bookmarks = append(bookmarks, bookmark)
+		}
+	}
+
+	chirps := []Chirp{}
+	for _, bookmark := range paginate(bookmarks, limit, offset) {
+		chirps = append(chirps, db.dbstruct.Chirps[bookmark.Chirp_id])
+	}
+
+	return chirps
+}
+
+// all of a user's bookmarks, most recent first
+// used by GetBookmarkedChirps and ExportUserData
+// caller must hold a Reader or Writer lock
+func (db *DB) bookmarksOf(userId int) []Bookmark {
+	bookmarks := []Bookmark{}
+	for chirpId, bookmarkedAt := range db.dbstruct.Bookmarks[userId] {
+		bookmarks = append(bookmarks, Bookmark{User_id: userId, Chirp_id: chirpId, Bookmarked_at: bookmarkedAt})
+	}
+	sort.Slice(bookmarks, func(i, j int) bool {
+		if !bookmarks[i].Bookmarked_at.Equal(bookmarks[j].Bookmarked_at) {
+			return bookmarks[i].Bookmarked_at.After(bookmarks[j].Bookmarked_at)
+		}
+		return bookmarks[i].Chirp_id > bookmarks[j].Chirp_id
+	})
+	return bookmarks
+}
+
+// caller must hold the Writer lock
+func (db *DB) removeBookmark(userId, chirpId int) {
+	delete(db.dbstruct.Bookmarks[userId], chirpId)
+	if len(db.dbstruct.Bookmarks[userId]) == 0 {
+		delete(db.dbstruct.Bookmarks, userId)
+	}
+}
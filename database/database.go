@@ -1,6 +1,8 @@
 package database
 
 import (
+	"chirpy/blobstore"
+	"chirpy/contentfilter"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,26 +11,112 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// returned when someone tries to change a chirp they didn't write
+var ErrNotAuthor = errors.New("you are not the author of that chirp")
+
+const maxBioLength = 160
+
 type DB struct {
 	path     string
 	mux      *sync.RWMutex
 	dbstruct *DBStructure
+	// what chirps, bios and messages are checked against
+	filter contentfilter.ContentFilter
+	// how long chirps can be, for everyone and for Chirpy Red users
+	chirpMaxLength    int
+	redChirpMaxLength int
+	// where uploaded files are, nil if uploads aren't stored anywhere
+	blobs blobstore.BlobStore
+	// indexes built from dbstruct when it is loaded, not saved to disk
+	likesByUser     map[int]map[int]time.Time
+	followersOf     map[int]map[int]time.Time
+	rechirpsByChirp map[int]map[int]int
+	usersByHandle   map[string]int
+	chirpsByHashtag map[string]map[int]bool
+	// search term -> chirp id -> positions of the term in the chirp
+	searchIndex map[string]map[int][]int
+	// parent chirp id -> ids of the chirps replying to it
+	repliesTo           map[int]map[int]bool
+	notificationsByUser map[int]map[int]bool
+	conversationsByUser map[int]map[int]bool
+	// conversation id -> ids of its messages, oldest first
+	messagesByConversation map[int][]int
 }
 
 type DBStructure struct {
 	Users                map[int]User    `json:"users"`
 	Chirps               map[int]Chirp   `json:"chirps"`
 	RevokedRefreshTokens map[string]bool `json:"revoked_refresh_tokens"`
+	Sequences            map[string]int  `json:"sequences"`
+	Exports              map[int]Export  `json:"exports"`
+	// chirp id -> id of the user who liked it -> when they liked it
+	Likes map[int]map[int]time.Time `json:"likes"`
+	// follower id -> id of the user they follow -> since when
+	Follows  map[int]map[int]time.Time `json:"follows"`
+	Rechirps map[int]Rechirp           `json:"rechirps"`
+	// requester id -> id of the protected user they asked to follow -> when they asked
+	FollowRequests map[int]map[int]time.Time `json:"follow_requests"`
+
+	Notifications           map[int]Notification            `json:"notifications"`
+	NotificationPreferences map[int]NotificationPreferences `json:"notification_preferences"`
+
+	Conversations map[int]Conversation `json:"conversations"`
+	Messages      map[int]Message      `json:"messages"`
+
+	// blocker id -> id of the user they blocked -> since when
+	Blocks map[int]map[int]time.Time `json:"blocks"`
+	// muter id -> id of the user they muted -> since when
+	Mutes map[int]map[int]time.Time `json:"mutes"`
+
+	Reports           map[int]Report           `json:"reports"`
+	ModerationActions map[int]ModerationAction `json:"moderation_actions"`
+
+	Media map[int]Media `json:"media"`
+
+	// chirp id -> id of the user who voted on its poll -> the option they voted for
+	PollVotes map[int]map[int]int `json:"poll_votes"`
+
+	Drafts map[int]Draft `json:"drafts"`
+
+	// user id -> id of the chirp they bookmarked -> when they bookmarked it
+	Bookmarks map[int]map[int]time.Time `json:"bookmarks"`
+	Lists     map[int]List              `json:"lists"`
+	// list id -> id of the user on it -> when they were added
+	ListMembers map[int]map[int]time.Time `json:"list_members"`
+
+	// user id -> id of the user they don't want recommended -> when they dismissed them
+	DismissedRecommendations map[int]map[int]time.Time `json:"dismissed_recommendations"`
+
+	// url -> its preview
+	LinkPreviews map[string]LinkPreview `json:"link_previews"`
 }
 
 type Chirp struct {
-	Id        int    `json:"id"`
-	Body      string `json:"body"`
-	Author_id int    `json:"author_id"`
+	Id         int       `json:"id"`
+	Body       string    `json:"body"`
+	Author_id  int       `json:"author_id"`
+	Created_at time.Time `json:"created_at"`
+	// set when the chirp's body was edited after it was created
+	Edited_at *time.Time `json:"edited_at,omitempty"`
+	// id of the chirp this one quotes, 0 if it isn't a quote chirp
+	Quote_of int `json:"quote_of,omitempty"`
+	// id of the chirp this one replies to, 0 if it isn't a reply
+	In_reply_to int `json:"in_reply_to,omitempty"`
+	// hashtags and mentions in the body, found when the chirp is created
+	Entities []Entity `json:"entities,omitempty"`
+	// set when a moderator hid the chirp, only its author can still see it
+	Hidden_at *time.Time `json:"hidden_at,omitempty"`
+	// ids of the uploaded images attached to the chirp, in order
+	Attachment_ids []int `json:"attachment_ids,omitempty"`
+	// a question the chirp asks, can only be added when the chirp is created
+	Poll *Poll `json:"poll,omitempty"`
+	// who can see the chirp: public, unlisted or followers, set when the chirp is created
+	Visibility string `json:"visibility"`
 }
 
 type User struct {
@@ -36,6 +124,24 @@ type User struct {
 	Email         string `json:"email"`
 	Password      string `json:"password"`
 	Is_chirpy_red bool   `json:"is_chirpy_red"`
+	// unique, used to @mention the user
+	Handle string `json:"handle,omitempty"`
+	// set when the user asked for their account to be deleted
+	// the account is hard deleted once the grace period has passed
+	Deactivated_at *time.Time `json:"deactivated_at,omitempty"`
+	// set when a moderator suspended the user, they can't log in or use their tokens until unsuspended
+	Suspended_at *time.Time `json:"suspended_at,omitempty"`
+	// a few words about themselves, shown on their profile
+	Bio string `json:"bio,omitempty"`
+	// only followers see a protected user's chirps, and they approve who follows them
+	Is_protected bool `json:"is_protected"`
+	// chirps shown at the top of their profile, most recently pinned first
+	Pinned_chirp_ids []int `json:"pinned_chirp_ids,omitempty"`
+}
+
+// IsDeactivated reports whether the user has requested deletion of their account
+func (user User) IsDeactivated() bool {
+	return user.Deactivated_at != nil
 }
 
 // CheckRefreshToken checks if a refresh token is revoked
@@ -68,21 +174,91 @@ func NewDB(path string) (*DB, error) {
 	}
 
 	db := DB{
-		path: path,
-		mux:  &sync.RWMutex{},
+		path:   path,
+		mux:    &sync.RWMutex{},
+		filter: contentfilter.Default(),
+
+		chirpMaxLength:    defaultChirpMaxLength,
+		redChirpMaxLength: defaultRedChirpMaxLength,
 		dbstruct: &DBStructure{
 			Users:                make(map[int]User), // need to allocate mem here to decode JSON into later, or store stuff
 			Chirps:               make(map[int]Chirp),
 			RevokedRefreshTokens: make(map[string]bool),
+			Sequences:            make(map[string]int),
+			Exports:              make(map[int]Export),
+			Likes:                make(map[int]map[int]time.Time),
+			Follows:              make(map[int]map[int]time.Time),
+			Rechirps:             make(map[int]Rechirp),
+			FollowRequests:       make(map[int]map[int]time.Time),
+
+			Notifications:           make(map[int]Notification),
+			NotificationPreferences: make(map[int]NotificationPreferences),
+
+			Conversations: make(map[int]Conversation),
+			Messages:      make(map[int]Message),
+
+			Blocks: make(map[int]map[int]time.Time),
+			Mutes:  make(map[int]map[int]time.Time),
+
+			Reports:           make(map[int]Report),
+			ModerationActions: make(map[int]ModerationAction),
+
+			Media: make(map[int]Media),
+
+			PollVotes: make(map[int]map[int]int),
+
+			Drafts: make(map[int]Draft),
+
+			Bookmarks:   make(map[int]map[int]time.Time),
+			Lists:       make(map[int]List),
+			ListMembers: make(map[int]map[int]time.Time),
+
+			DismissedRecommendations: make(map[int]map[int]time.Time),
+
+			LinkPreviews: make(map[string]LinkPreview),
 		},
 	}
 
 	// load the JSON file contents into mem
 	db.loadDB()
+	db.indexLikes()
+	db.indexFollows()
+	db.indexRechirps()
+	db.indexEntities()
+	db.indexSearch()
+	db.indexReplies()
+	db.indexNotifications()
+	db.indexConversations()
+
+	// databases written before sequences existed only have their max ids to go off of
+	for id := range db.dbstruct.Users {
+		if id > db.dbstruct.Sequences["users"] {
+			db.dbstruct.Sequences["users"] = id
+		}
+	}
+	for id, chirp := range db.dbstruct.Chirps {
+		if id > db.dbstruct.Sequences["chirps"] {
+			db.dbstruct.Sequences["chirps"] = id
+		}
+		// chirps written before visibility existed are public
+		if chirp.Visibility == "" {
+			chirp.Visibility = VisibilityPublic
+			db.dbstruct.Chirps[id] = chirp
+		}
+	}
 
 	return &db, nil
 }
 
+// nextId returns a new id for the given kind of record, e.g. "users"
+// ids are never handed out twice, even after the record that had it is deleted,
+// so stale references (like an access token's subject) can't end up pointing at someone else
+// caller must hold the Writer lock
+func (db *DB) nextId(kind string) int {
+	db.dbstruct.Sequences[kind]++
+	return db.dbstruct.Sequences[kind]
+}
+
 // CreateNewUser creates a new user and saves it to disk
 func (db *DB) CreateNewUser(user User) User {
 	// only one Writer at a time can create new Users
@@ -90,13 +266,7 @@ func (db *DB) CreateNewUser(user User) User {
 	defer db.mux.Unlock()
 
 	// get new id
-	maxId := 0
-	for id := range db.dbstruct.Users {
-		if id > maxId {
-			maxId = id
-		}
-	}
-	newId := maxId + 1
+	newId := db.nextId("users")
 
 	// add in the id
 	user.Id = newId
@@ -113,79 +283,247 @@ func (db *DB) CreateNewUser(user User) User {
 
 	// save newUser to mem and disk
 	db.dbstruct.Users[newId] = user
+	if user.Handle != "" {
+		db.usersByHandle[strings.ToLower(user.Handle)] = newId
+	}
 	db.writeDB()
 
 	return user
 }
 
-// censors bad words from a string by replacing them with some censor
-// listOfBadWords is a list of strings of bad words to censor
-// s is the string to sensor
-// badWordReplacement is the censor string (replacement val)
-func censorChirp(listOfBadWords []string, s, badWordReplacement string) string {
-	chirpWords := strings.Split(s, " ")
-	for i, word := range chirpWords {
-		for _, badWord := range listOfBadWords {
-			if strings.ToLower(word) == badWord {
-				chirpWords[i] = badWordReplacement
-			}
-		}
-	}
-	return strings.Join(chirpWords, " ")
-}
-
 // CreateChirp creates a new chirp and saves it to disk
 func (db *DB) CreateChirp(newChirp Chirp) (Chirp, error) {
 	// only one Writer at a time can create new Chirps
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
-	// check if chirp is too long
-	if len(newChirp.Body) > 140 {
-		return newChirp, errors.New("chirp is too long")
+	newChirp, err := db.createChirp(newChirp)
+	if err != nil {
+		return newChirp, err
 	}
+	db.writeDB()
 
-	// censor chirp
-	badWordReplacement := "****"
-	listOfBadWords := []string{"kerfuffle", "sharbert", "fornax"}
-	cleanedChirpBody := censorChirp(listOfBadWords, newChirp.Body, badWordReplacement)
+	return newChirp, nil
+}
+
+// createChirp checks a new chirp and stores it
+// used by CreateChirp and PublishDraft
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) createChirp(newChirp Chirp) (Chirp, error) {
+	// check length and filter
+	cleanedChirpBody, flaggedBy, err := db.cleanChirpBody(newChirp.Body, newChirp.Author_id)
+	if err != nil {
+		return newChirp, err
+	}
 	newChirp.Body = cleanedChirpBody
 
-	// give chirp a new id
-	maxId := 0
-	for id := range db.dbstruct.Chirps {
-		if id > maxId {
-			maxId = id
+	newChirp.Visibility, err = checkVisibility(newChirp.Visibility)
+	if err != nil {
+		return newChirp, err
+	}
+
+	// quote chirps need something to quote
+	if newChirp.Quote_of != 0 {
+		quoted, ok := db.dbstruct.Chirps[newChirp.Quote_of]
+		if !ok || db.isUserDeactivated(quoted.Author_id) || quoted.IsHidden() || db.isVisibilityHiddenFrom(quoted, newChirp.Author_id) ||
+			db.isProtectedFrom(quoted.Author_id, newChirp.Author_id) {
+			return newChirp, fmt.Errorf("quoted chirp with ID %d not found", newChirp.Quote_of)
 		}
+		if db.isBlocked(newChirp.Author_id, quoted.Author_id) {
+			return newChirp, ErrBlocked
+		}
+		// quoting would show it to whoever can see the quote
+		if (quoted.Visibility == VisibilityFollowers || db.dbstruct.Users[quoted.Author_id].Is_protected) && quoted.Author_id != newChirp.Author_id {
+			return newChirp, errors.New("followers-only chirps and chirps by protected users can only be quoted by their author")
+		}
+	}
+
+	// so do replies
+	if newChirp.In_reply_to != 0 {
+		parent, ok := db.dbstruct.Chirps[newChirp.In_reply_to]
+		if !ok || db.isUserDeactivated(parent.Author_id) || parent.IsHidden() || db.isVisibilityHiddenFrom(parent, newChirp.Author_id) ||
+			db.isProtectedFrom(parent.Author_id, newChirp.Author_id) {
+			return newChirp, fmt.Errorf("chirp with ID %d to reply to not found", newChirp.In_reply_to)
+		}
+		if db.isBlocked(newChirp.Author_id, parent.Author_id) {
+			return newChirp, ErrBlocked
+		}
+	}
+
+	if err := db.checkAttachments(newChirp.Attachment_ids, newChirp.Author_id); err != nil {
+		return newChirp, err
+	}
+
+	now := time.Now()
+	if newChirp.Poll != nil {
+		pollFlaggedBy, err := db.preparePoll(newChirp.Poll, now)
+		if err != nil {
+			return newChirp, err
+		}
+		flaggedBy = append(flaggedBy, pollFlaggedBy...)
 	}
-	newId := maxId + 1
+
+	// give chirp a new id
+	newId := db.nextId("chirps")
 	newChirp.Id = newId
+	newChirp.Created_at = now
+
+	// find the hashtags and mentions
+	newChirp.Entities = db.extractEntities(newChirp.Body, newChirp.Author_id)
+	db.indexHashtags(newChirp)
+	db.indexChirpText(newChirp)
+	db.indexReply(newChirp)
+
+	for _, mediaId := range newChirp.Attachment_ids {
+		media := db.dbstruct.Media[mediaId]
+		media.Chirp_id = newId
+		db.dbstruct.Media[mediaId] = media
+	}
 
-	// save newChirp to mem and disk
+	// save newChirp to mem
 	db.dbstruct.Chirps[newId] = newChirp
-	db.writeDB()
+	db.fileFilterReport(Report{Target_type: ReportTargetChirp, Chirp_id: newId, User_id: newChirp.Author_id}, flaggedBy)
 
 	return newChirp, nil
 }
 
-// UpdateUser updates a user in the database
-func (db *DB) UpdateUser(user User) User {
-	// only one Writer at a time can update Users
+// UpdateChirp replaces the body of a chirp, only its author can edit it
+// the new body goes through the same checks as a new chirp
+func (db *DB) UpdateChirp(chirpId, authorId int, body string) (Chirp, error) {
+	// Writer lock
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
-	// store the hashed password
-	hashedPassBytes, err := bcrypt.GenerateFromPassword([]byte(user.Password), 13)
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isUserDeactivated(chirp.Author_id) {
+		return Chirp{}, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+	if chirp.Author_id != authorId {
+		return Chirp{}, ErrNotAuthor
+	}
+
+	cleanedChirpBody, flaggedBy, err := db.cleanChirpBody(body, chirp.Author_id)
 	if err != nil {
-		log.Fatal(err)
+		return chirp, err
 	}
-	user.Password = string(hashedPassBytes)
 
-	// save newUser to mem and disk
-	db.dbstruct.Users[user.Id] = user
+	// take the old body out of the indexes before replacing it
+	db.unindexHashtags(chirp)
+	db.unindexChirpText(chirp)
+
+	now := time.Now()
+	chirp.Body = cleanedChirpBody
+	chirp.Edited_at = &now
+	chirp.Entities = db.extractEntities(chirp.Body, chirp.Author_id)
+
+	db.indexHashtags(chirp)
+	db.indexChirpText(chirp)
+
+	db.dbstruct.Chirps[chirpId] = chirp
+	db.fileFilterReport(Report{Target_type: ReportTargetChirp, Chirp_id: chirpId, User_id: chirp.Author_id}, flaggedBy)
 	db.writeDB()
 
-	return user
+	return chirp, nil
+}
+
+// used by CreateChirp and UpdateChirp
+// checks the length of a chirp body against its author's limit and runs it through the content filter
+// caller must hold a Reader or Writer lock
+func (db *DB) cleanChirpBody(body string, authorId int) (string, []string, error) {
+	// check if chirp is too long
+	if maxLength := db.chirpMaxLengthFor(authorId); isChirpTooLong(body, maxLength) {
+		return body, nil, fmt.Errorf("chirp is too long, the limit is %d characters", maxLength)
+	}
+
+	return db.filterText(contentfilter.KindChirp, body)
+}
+
+// UserUpdate holds the changes a user makes to their account, nil fields are left as they are
+type UserUpdate struct {
+	Email *string
+	// hashed by the caller, so the Writer lock isn't held while bcrypt runs
+	Hashed_password *string
+	Handle          *string
+	Bio             *string
+	Is_protected    *bool
+}
+
+// returned when a user changes their email or handle to one someone else has
+var (
+	ErrEmailInUse  = errors.New("email is already in use")
+	ErrHandleInUse = errors.New("handle is already in use")
+)
+
+// UpdateUser applies all the changes a user makes to their account at once,
+// nothing is changed if any of them is invalid
+// the bio goes through the content filter, and turning protection off approves the pending follow requests
+func (db *DB) UpdateUser(userId int, update UserUpdate) (User, error) {
+	// only one Writer at a time can update Users
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return User{}, errors.New("user not found")
+	}
+
+	if update.Email != nil {
+		for _, other := range db.dbstruct.Users {
+			if other.Id != userId && other.Email == *update.Email {
+				return user, ErrEmailInUse
+			}
+		}
+		user.Email = *update.Email
+	}
+	if update.Hashed_password != nil {
+		user.Password = *update.Hashed_password
+	}
+
+	oldHandle := user.Handle
+	if update.Handle != nil {
+		if err := ValidateHandle(*update.Handle); err != nil {
+			return user, err
+		}
+		if ownerId, ok := db.usersByHandle[strings.ToLower(*update.Handle)]; ok && ownerId != userId {
+			return user, ErrHandleInUse
+		}
+		user.Handle = *update.Handle
+	}
+
+	var flaggedBy []string
+	if update.Bio != nil {
+		if isTooLong(*update.Bio, maxBioLength) {
+			return user, errors.New("bio is too long")
+		}
+		bio, flagged, err := db.filterText(contentfilter.KindBio, strings.TrimSpace(*update.Bio))
+		if err != nil {
+			return user, err
+		}
+		user.Bio = bio
+		flaggedBy = flagged
+	}
+
+	// everything is valid, store the changes
+	if update.Is_protected != nil && user.Is_protected != *update.Is_protected {
+		user.Is_protected = *update.Is_protected
+		if !user.Is_protected {
+			db.approveFollowRequests(userId)
+		}
+	}
+
+	// keep the handle index up to date if the handle changed
+	if oldHandle != "" {
+		delete(db.usersByHandle, strings.ToLower(oldHandle))
+	}
+	if user.Handle != "" {
+		db.usersByHandle[strings.ToLower(user.Handle)] = user.Id
+	}
+
+	db.dbstruct.Users[userId] = user
+	db.fileFilterReport(Report{Target_type: ReportTargetUser, User_id: userId}, flaggedBy)
+	db.writeDB()
+
+	return user, nil
 }
 
 // UgradeUserToChirpyRed upgrades a user to Chirpy Red status
@@ -211,7 +549,7 @@ func (db *DB) DeleteChirp(chirpId int) error {
 
 	// delete the chirp if exist
 	if _, ok := db.dbstruct.Chirps[chirpId]; ok {
-		delete(db.dbstruct.Chirps, chirpId)
+		db.deleteChirp(chirpId)
 	} else {
 		return errors.New("chirp doesn't exist")
 	}
@@ -222,6 +560,41 @@ func (db *DB) DeleteChirp(chirpId int) error {
 	return nil
 }
 
+// deleteChirp removes a chirp and everything attached to it
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) deleteChirp(chirpId int) {
+	for userId := range db.dbstruct.Likes[chirpId] {
+		db.removeLike(userId, chirpId)
+	}
+
+	// quotes of the chirp stay, their Quote_of just points at nothing anymore
+	for _, rechirpId := range db.rechirpsByChirp[chirpId] {
+		db.removeRechirp(rechirpId)
+	}
+
+	db.unindexHashtags(db.dbstruct.Chirps[chirpId])
+	db.unindexChirpText(db.dbstruct.Chirps[chirpId])
+
+	// replies to the chirp stay, their In_reply_to just points at nothing anymore
+	db.unindexReply(db.dbstruct.Chirps[chirpId])
+	delete(db.repliesTo, chirpId)
+
+	for id, notification := range db.dbstruct.Notifications {
+		if notification.Chirp_id == chirpId {
+			db.removeNotification(id)
+		}
+	}
+	for _, mediaId := range db.dbstruct.Chirps[chirpId].Attachment_ids {
+		db.removeMedia(mediaId)
+	}
+	delete(db.dbstruct.PollVotes, chirpId)
+	db.unpinChirp(db.dbstruct.Chirps[chirpId].Author_id, chirpId)
+	for userId := range db.dbstruct.Bookmarks {
+		db.removeBookmark(userId, chirpId)
+	}
+	delete(db.dbstruct.Chirps, chirpId)
+}
+
 // GetUser returns a SINGLE user from the database, if you know the id
 func (db *DB) GetUser(id int) (User, error) {
 	// lock for Readers
@@ -258,9 +631,9 @@ func (db *DB) GetChirp(id int) (Chirp, error) {
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 
-	// get chirp if exists
+	// get chirp if exists, chirps of deactivated users are hidden
 	chirp, ok := db.dbstruct.Chirps[id]
-	if !ok {
+	if !ok || db.isUserDeactivated(chirp.Author_id) {
 		return Chirp{}, fmt.Errorf("chirp with ID %d not found", id)
 	}
 
@@ -268,15 +641,17 @@ func (db *DB) GetChirp(id int) (Chirp, error) {
 }
 
 // GetChirpsByAuthor returns a list of all the Chirps by the provided author/User
-// returns an empty list if the User has no Chirps or if the User doesn't exist
-func (db *DB) GetChirpsByAuthor(authorId int, orderScheme string) []Chirp {
+// returns an empty list if the User has no Chirps, doesn't exist or blocked the viewer (or the other way around)
+// pinned chirps come first whatever the order, and aren't repeated further down
+func (db *DB) GetChirpsByAuthor(authorId int, orderScheme string, viewerId int) []Chirp {
 	// Readers lock
 	db.mux.RLock()
 	defer db.mux.RUnlock()
 
+	pinned := db.pinnedChirps(authorId, viewerId)
 	chirps := []Chirp{}
 	for _, chirp := range db.dbstruct.Chirps {
-		if chirp.Author_id == authorId {
+		if chirp.Author_id == authorId && !db.isChirpHiddenFrom(chirp, viewerId) && !isPinned(db.dbstruct.Users[authorId], chirp.Id) {
 			chirps = append(chirps, chirp)
 		}
 	}
@@ -292,12 +667,12 @@ func (db *DB) GetChirpsByAuthor(authorId int, orderScheme string) []Chirp {
 		})
 	}
 
-	return chirps
+	return append(pinned, chirps...)
 }
 
-// GetChirps returns all chirps in the database
+// GetChirps returns all chirps in the database the viewer can see
 // order by id in ascending order
-func (db *DB) GetChirps(orderScheme string) []Chirp {
+func (db *DB) GetChirps(orderScheme string, viewerId int) []Chirp {
 	// lock for Readers
 	db.mux.RLock()
 	defer db.mux.RUnlock()
@@ -305,7 +680,9 @@ func (db *DB) GetChirps(orderScheme string) []Chirp {
 	// get the list of chirps
 	chirps := []Chirp{}
 	for _, chirp := range db.dbstruct.Chirps {
-		chirps = append(chirps, chirp)
+		if chirp.IsListed() && !db.isChirpHiddenFrom(chirp, viewerId) {
+			chirps = append(chirps, chirp)
+		}
 	}
 
 	// Sort slice of Chirp objects by ID, asc or desc
@@ -365,3 +742,15 @@ func (db *DB) writeDB() error {
 
 	return nil
 }
+
+// paginate returns the page of items starting at offset, at most limit long
+func paginate[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
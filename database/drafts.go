@@ -0,0 +1,270 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// statuses of a Draft
+const (
+	DraftSaved     = "draft"
+	DraftScheduled = "scheduled"
+	// the scheduler couldn't publish it, Error says why
+	DraftFailed = "failed"
+)
+
+// limits for drafts
+const (
+	maxDraftsPerUser = 100
+	maxScheduleAhead = 365 * 24 * time.Hour
+)
+
+// Draft is a chirp that isn't published yet, saved for later or scheduled to be published at a set time
+// it is checked like any chirp when it is published, and removed once it is
+type Draft struct {
+	Id             int    `json:"id"`
+	Author_id      int    `json:"author_id"`
+	Body           string `json:"body"`
+	Quote_of       int    `json:"quote_of,omitempty"`
+	In_reply_to    int    `json:"in_reply_to,omitempty"`
+	Attachment_ids []int  `json:"attachment_ids,omitempty"`
+	Poll           *Poll  `json:"poll,omitempty"`
+	Visibility     string `json:"visibility,omitempty"`
+	// when to publish it, nil for drafts that are only saved
+	Scheduled_at *time.Time `json:"scheduled_at,omitempty"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	Updated_at   time.Time  `json:"updated_at"`
+}
+
+// SaveDraft creates a draft, or replaces one of the author's drafts if draft.Id is set
+// a draft with Scheduled_at is scheduled, without it is only saved
+func (db *DB) SaveDraft(draft Draft) (Draft, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	now := time.Now()
+	if draft.Scheduled_at != nil {
+		if !draft.Scheduled_at.After(now) {
+			return Draft{}, errors.New("scheduled_at must be in the future")
+		}
+		if draft.Scheduled_at.After(now.Add(maxScheduleAhead)) {
+			return Draft{}, errors.New("chirps can be scheduled at most a year ahead")
+		}
+	}
+
+	// catch what can be caught now, the rest is checked when the chirp is published
+	if maxLength := db.chirpMaxLengthFor(draft.Author_id); isChirpTooLong(draft.Body, maxLength) {
+		return Draft{}, fmt.Errorf("chirp is too long, the limit is %d characters", maxLength)
+	}
+	if err := db.checkAttachments(draft.Attachment_ids, draft.Author_id); err != nil {
+		return Draft{}, err
+	}
+	if _, err := checkVisibility(draft.Visibility); err != nil {
+		return Draft{}, err
+	}
+
+	if draft.Id == 0 {
+		count := 0
+		for _, existing := range db.dbstruct.Drafts {
+			if existing.Author_id == draft.Author_id {
+				count++
+			}
+		}
+		if count >= maxDraftsPerUser {
+			return Draft{}, fmt.Errorf("you can have at most %d drafts and scheduled chirps", maxDraftsPerUser)
+		}
+		draft.Id = db.nextId("drafts")
+		draft.Created_at = now
+	} else {
+		existing, ok := db.dbstruct.Drafts[draft.Id]
+		if !ok || existing.Author_id != draft.Author_id {
+			return Draft{}, fmt.Errorf("draft with ID %d not found", draft.Id)
+		}
+		draft.Created_at = existing.Created_at
+	}
+
+	draft.Status = DraftSaved
+	if draft.Scheduled_at != nil {
+		draft.Status = DraftScheduled
+	}
+	draft.Error = ""
+	draft.Updated_at = now
+	db.dbstruct.Drafts[draft.Id] = draft
+	db.writeDB()
+
+	return draft, nil
+}
+
+// GetDraft returns one of the author's drafts
+func (db *DB) GetDraft(draftId, authorId int) (Draft, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	draft, ok := db.dbstruct.Drafts[draftId]
+	if !ok || draft.Author_id != authorId {
+		return Draft{}, fmt.Errorf("draft with ID %d not found", draftId)
+	}
+
+	return draft, nil
+}
+
+// GetDrafts returns a user's drafts with the given status, or all of them for an empty status
+// scheduled chirps come first, the soonest first, then the rest, most recently updated first
+func (db *DB) GetDrafts(authorId int, status string, limit, offset int) []Draft {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	drafts := []Draft{}
+	for _, draft := range db.dbstruct.Drafts {
+		if draft.Author_id == authorId && (status == "" || draft.Status == status) {
+			drafts = append(drafts, draft)
+		}
+	}
+	sort.Slice(drafts, func(i, j int) bool {
+		a, b := drafts[i], drafts[j]
+		if (a.Status == DraftScheduled) != (b.Status == DraftScheduled) {
+			return a.Status == DraftScheduled
+		}
+		if a.Status == DraftScheduled && !a.Scheduled_at.Equal(*b.Scheduled_at) {
+			return a.Scheduled_at.Before(*b.Scheduled_at)
+		}
+		if !a.Updated_at.Equal(b.Updated_at) {
+			return a.Updated_at.After(b.Updated_at)
+		}
+		return a.Id > b.Id
+	})
+
+	return paginate(drafts, limit, offset)
+}
+
+// DeleteDraft removes one of the author's drafts, which cancels it if it was scheduled
+func (db *DB) DeleteDraft(draftId, authorId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	draft, ok := db.dbstruct.Drafts[draftId]
+	if !ok || draft.Author_id != authorId {
+		return fmt.Errorf("draft with ID %d not found", draftId)
+	}
+	delete(db.dbstruct.Drafts, draftId)
+	db.writeDB()
+
+	return nil
+}
+
+// PublishDraft turns a draft into a chirp, with the same checks as CreateChirp, and removes the draft
+// authorId 0 is the scheduler, which only publishes scheduled drafts
+// when the scheduler can't publish a draft it is kept as failed, with the reason
+func (db *DB) PublishDraft(draftId, authorId int) (Chirp, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	draft, ok := db.dbstruct.Drafts[draftId]
+	if !ok || (authorId != 0 && draft.Author_id != authorId) || (authorId == 0 && draft.Status != DraftScheduled) {
+		return Chirp{}, fmt.Errorf("draft with ID %d not found", draftId)
+	}
+
+	var chirp Chirp
+	var err error
+	if db.isUserDeactivated(draft.Author_id) || db.dbstruct.Users[draft.Author_id].IsSuspended() {
+		err = errors.New("the author's account can't publish chirps")
+	} else {
+		chirp, err = db.createChirp(draftChirp(draft))
+	}
+	if err != nil {
+		if authorId == 0 {
+			draft.Status = DraftFailed
+			draft.Error = err.Error()
+			draft.Updated_at = time.Now()
+			db.dbstruct.Drafts[draftId] = draft
+			db.writeDB()
+		}
+		return Chirp{}, err
+	}
+
+	delete(db.dbstruct.Drafts, draftId)
+	db.writeDB()
+
+	return chirp, nil
+}
+
+// GetDueDrafts returns the ids of the scheduled drafts whose time has come, the earliest first
+func (db *DB) GetDueDrafts(now time.Time) []int {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	due := []Draft{}
+	for _, draft := range db.dbstruct.Drafts {
+		if draft.Status == DraftScheduled && !draft.Scheduled_at.After(now) {
+			due = append(due, draft)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Scheduled_at.Before(*due[j].Scheduled_at)
+	})
+
+	ids := []int{}
+	for _, draft := range due {
+		ids = append(ids, draft.Id)
+	}
+	return ids
+}
+
+// NextScheduledAt returns when the next scheduled draft is due, false if nothing is scheduled
+func (db *DB) NextScheduledAt() (time.Time, bool) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	var next time.Time
+	found := false
+	for _, draft := range db.dbstruct.Drafts {
+		if draft.Status == DraftScheduled && (!found || draft.Scheduled_at.Before(next)) {
+			next = *draft.Scheduled_at
+			found = true
+		}
+	}
+	return next, found
+}
+
+// the chirp a draft becomes
+// the poll is copied so checking it can't change the stored draft
+func draftChirp(draft Draft) Chirp {
+	chirp := Chirp{
+		Body:           draft.Body,
+		Author_id:      draft.Author_id,
+		Quote_of:       draft.Quote_of,
+		In_reply_to:    draft.In_reply_to,
+		Attachment_ids: draft.Attachment_ids,
+		Visibility:     draft.Visibility,
+	}
+	if draft.Poll != nil {
+		poll := *draft.Poll
+		poll.Options = append([]string{}, draft.Poll.Options...)
+		chirp.Poll = &poll
+	}
+	return chirp
+}
+
+// isInDraft checks if an upload is used by a draft, so it isn't purged while it waits to be published
+// caller must hold a Reader or Writer lock
+func (db *DB) isInDraft(mediaId int) bool {
+	for _, draft := range db.dbstruct.Drafts {
+		for _, id := range draft.Attachment_ids {
+			if id == mediaId {
+				return true
+			}
+		}
+	}
+	return false
+}
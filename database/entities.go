@@ -0,0 +1,226 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// kinds of Entity
+const (
+	EntityHashtag = "hashtag"
+	EntityMention = "mention"
+	EntityLink    = "link"
+)
+
+// Entity is a piece of structure found in a chirp's body, like a #hashtag, an @mention or a link
+// Start and End are offsets in characters (unicode code points) into the body, End is exclusive
+type Entity struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"` // the tag or handle without the leading # or @, or the url of a link
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	// the mentioned user, only set for mentions
+	User_id int `json:"user_id,omitempty"`
+}
+
+// longest allowed handle, mentions are cut off there too
+const maxHandleLength = 15
+
+// ValidateHandle checks that a handle is 1 to 15 letters, digits or underscores
+func ValidateHandle(handle string) error {
+	if len(handle) == 0 || len(handle) > maxHandleLength {
+		return fmt.Errorf("handle must be 1 to %d characters long", maxHandleLength)
+	}
+	for _, c := range handle {
+		if !isHandleChar(c) {
+			return errors.New("handle can only contain letters, digits and underscores")
+		}
+	}
+	return nil
+}
+
+// GetUserByHandle returns the user with the given handle, ignoring case
+func (db *DB) GetUserByHandle(handle string) (User, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	userId, ok := db.usersByHandle[strings.ToLower(handle)]
+	if !ok {
+		return User{}, fmt.Errorf("user with handle @%s not found", handle)
+	}
+
+	return db.dbstruct.Users[userId], nil
+}
+
+// GetChirpsByHashtag returns the chirps tagged with a hashtag the viewer can see, ignoring case, newest first
+func (db *DB) GetChirpsByHashtag(tag string, viewerId, limit, offset int) []Chirp {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	chirps := []Chirp{}
+	for chirpId := range db.chirpsByHashtag[strings.ToLower(tag)] {
+		chirp := db.dbstruct.Chirps[chirpId]
+		if chirp.IsListed() && !db.isChirpHiddenFrom(chirp, viewerId) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	sort.Slice(chirps, func(i, j int) bool {
+		return chirps[i].Id > chirps[j].Id
+	})
+
+	return paginate(chirps, limit, offset)
+}
+
+// extractEntities finds the links, hashtags and mentions in a chirp body
+// mentions only count if the handle belongs to an existing, active user who isn't blocked from or by the author
+// caller must hold a Reader or Writer lock
+func (db *DB) extractEntities(body string, authorId int) []Entity {
+	runes := []rune(body)
+	entities := []Entity{}
+
+	for i := 0; i < len(runes); i++ {
+		// links first, so the #fragments and @s in them aren't taken for hashtags and mentions
+		if end := linkEnd(runes, i); end != 0 {
+			entities = append(entities, Entity{Type: EntityLink, Text: string(runes[i:end]), Start: i, End: end})
+			i = end - 1
+			continue
+		}
+		if runes[i] != '#' && runes[i] != '@' {
+			continue
+		}
+		// "a#b" and "me@example.com" aren't tags or mentions
+		if i > 0 && isHandleChar(runes[i-1]) {
+			continue
+		}
+
+		end := i + 1
+		if runes[i] == '#' {
+			for end < len(runes) && isHashtagChar(runes[end]) {
+				end++
+			}
+			tag := string(runes[i+1 : end])
+			// a tag needs at least one letter, "#1" is just a number
+			if strings.IndexFunc(tag, unicode.IsLetter) == -1 {
+				continue
+			}
+			entities = append(entities, Entity{Type: EntityHashtag, Text: tag, Start: i, End: end})
+		} else {
+			for end < len(runes) && end-i-1 < maxHandleLength && isHandleChar(runes[end]) {
+				end++
+			}
+			// a longer run of handle characters can't be a real handle
+			if end < len(runes) && isHandleChar(runes[end]) {
+				continue
+			}
+			handle := string(runes[i+1 : end])
+			userId, ok := db.usersByHandle[strings.ToLower(handle)]
+			if handle == "" || !ok || db.isHiddenFrom(userId, authorId) {
+				continue
+			}
+			entities = append(entities, Entity{Type: EntityMention, Text: handle, Start: i, End: end, User_id: userId})
+		}
+		i = end - 1
+	}
+
+	return entities
+}
+
+// indexHashtags adds a chirp to the hashtag index
+// caller must hold the Writer lock
+func (db *DB) indexHashtags(chirp Chirp) {
+	for _, entity := range chirp.Entities {
+		if entity.Type != EntityHashtag {
+			continue
+		}
+		tag := strings.ToLower(entity.Text)
+		if db.chirpsByHashtag[tag] == nil {
+			db.chirpsByHashtag[tag] = make(map[int]bool)
+		}
+		db.chirpsByHashtag[tag][chirp.Id] = true
+	}
+}
+
+// unindexHashtags removes a chirp from the hashtag index
+// caller must hold the Writer lock
+func (db *DB) unindexHashtags(chirp Chirp) {
+	for _, entity := range chirp.Entities {
+		if entity.Type != EntityHashtag {
+			continue
+		}
+		tag := strings.ToLower(entity.Text)
+		delete(db.chirpsByHashtag[tag], chirp.Id)
+		if len(db.chirpsByHashtag[tag]) == 0 {
+			delete(db.chirpsByHashtag, tag)
+		}
+	}
+}
+
+// indexEntities builds the handle and hashtag indexes from the stored users and chirps
+// used by NewDB after loading the db
+func (db *DB) indexEntities() {
+	db.usersByHandle = make(map[string]int)
+	for _, user := range db.dbstruct.Users {
+		if user.Handle != "" {
+			db.usersByHandle[strings.ToLower(user.Handle)] = user.Id
+		}
+	}
+
+	db.chirpsByHashtag = make(map[string]map[int]bool)
+	for _, chirp := range db.dbstruct.Chirps {
+		db.indexHashtags(chirp)
+	}
+}
+
+// returns where a http or https link starting at runes[i] ends, or 0 if no link starts there
+// a link runs until the next space, without the punctuation ending the sentence it is in
+func linkEnd(runes []rune, i int) int {
+	if runes[i] != 'h' && runes[i] != 'H' {
+		return 0
+	}
+	// "xhttp://" isn't a link
+	if i > 0 && (unicode.IsLetter(runes[i-1]) || unicode.IsDigit(runes[i-1])) {
+		return 0
+	}
+
+	end := i
+	for end < len(runes) && !unicode.IsSpace(runes[end]) {
+		end++
+	}
+	for end > i {
+		last := runes[end-1]
+		link := string(runes[i:end])
+		// a closing bracket is part of the link if it closes one opened in the link, like in wikipedia urls
+		if strings.ContainsRune(".,;:!?'\"", last) || (last == ')' && strings.Count(link, "(") < strings.Count(link, ")")) {
+			end--
+			continue
+		}
+		break
+	}
+
+	link := string(runes[i:end])
+	lower := strings.ToLower(link)
+	if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+		return 0
+	}
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Hostname() == "" {
+		return 0
+	}
+	return end
+}
+
+// letters, digits and underscores
+func isHandleChar(c rune) bool {
+	return c == '_' || (c < unicode.MaxASCII && (unicode.IsLetter(c) || unicode.IsDigit(c)))
+}
+
+// hashtags can use letters and digits of any script, and underscores
+func isHashtagChar(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c) || unicode.Is(unicode.Mn, c)
+}
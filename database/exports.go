@@ -0,0 +1,349 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// statuses an Export job goes through
+const (
+	ExportPending  = "pending"
+	ExportRunning  = "running"
+	ExportComplete = "complete"
+	ExportFailed   = "failed"
+)
+
+// Export is a background job generating a personal data archive for a user
+type Export struct {
+	Id           int        `json:"id"`
+	User_id      int        `json:"user_id"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	Created_at   time.Time  `json:"created_at"`
+	Completed_at *time.Time `json:"completed_at,omitempty"`
+	// file name of the finished archive, in the user's directory of data exports
+	File string `json:"file,omitempty"`
+}
+
+// UserArchive is everything stored about a user, as handed to them in a data export
+type UserArchive struct {
+	Generated_at time.Time      `json:"generated_at"`
+	Profile      ArchiveProfile `json:"profile"`
+	Chirps       []Chirp        `json:"chirps"`
+	Likes        []Like         `json:"likes"`
+	Rechirps     []Rechirp      `json:"rechirps"`
+	Following    []Follow       `json:"following"`
+	Followers    []Follow       `json:"followers"`
+	// only the follow requests the user sent that are still pending
+	Follow_requests []FollowRequest `json:"follow_requests"`
+
+	Notifications           []Notification          `json:"notifications"`
+	NotificationPreferences NotificationPreferences `json:"notification_preferences"`
+
+	Conversations []Conversation `json:"conversations"`
+	// only the messages the user sent
+	Messages []Message `json:"messages"`
+
+	// only the blocks and mutes made by the user, not the ones against them
+	Blocks []Block `json:"blocks"`
+	Mutes  []Mute  `json:"mutes"`
+	// only the reports the user filed
+	Reports []Report `json:"reports"`
+	// the images the user uploaded, the files are in the archive's media folder under their keys
+	Media []Media `json:"media"`
+	// the user's votes on polls
+	Poll_votes []PollVote `json:"poll_votes"`
+	// drafts and scheduled chirps that aren't published yet
+	Drafts []Draft `json:"drafts"`
+
+	Bookmarks    []Bookmark   `json:"bookmarks"`
+	Lists        []List       `json:"lists"`
+	List_members []ListMember `json:"list_members"`
+	// users the user doesn't want recommended
+	Dismissed_recommendations []Dismissal `json:"dismissed_recommendations"`
+}
+
+// ArchiveProfile is a User without its password hash
+type ArchiveProfile struct {
+	Id               int        `json:"id"`
+	Email            string     `json:"email"`
+	Is_chirpy_red    bool       `json:"is_chirpy_red"`
+	Handle           string     `json:"handle,omitempty"`
+	Bio              string     `json:"bio,omitempty"`
+	Is_protected     bool       `json:"is_protected"`
+	Pinned_chirp_ids []int      `json:"pinned_chirp_ids,omitempty"`
+	Deactivated_at   *time.Time `json:"deactivated_at,omitempty"`
+	Suspended_at     *time.Time `json:"suspended_at,omitempty"`
+}
+
+// CreateExport queues a new export job for a user
+// a user can only have one unfinished export at a time
+func (db *DB) CreateExport(userId int) (Export, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok {
+		return Export{}, errors.New("user not found")
+	}
+	for _, export := range db.dbstruct.Exports {
+		if export.User_id == userId && (export.Status == ExportPending || export.Status == ExportRunning) {
+			return export, errors.New("an export is already in progress")
+		}
+	}
+
+	export := Export{
+		Id:         db.nextId("exports"),
+		User_id:    userId,
+		Status:     ExportPending,
+		Created_at: time.Now(),
+	}
+	db.dbstruct.Exports[export.Id] = export
+	db.writeDB()
+
+	return export, nil
+}
+
+// UpdateExport saves the progress of an export job
+func (db *DB) UpdateExport(export Export) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Exports[export.Id]; !ok {
+		return fmt.Errorf("export with ID %d not found", export.Id)
+	}
+	db.dbstruct.Exports[export.Id] = export
+	db.writeDB()
+
+	return nil
+}
+
+// GetExport returns a SINGLE export job, if you know the id
+func (db *DB) GetExport(id int) (Export, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	export, ok := db.dbstruct.Exports[id]
+	if !ok {
+		return Export{}, fmt.Errorf("export with ID %d not found", id)
+	}
+
+	return export, nil
+}
+
+// GetUnfinishedExports returns the export jobs that are not complete or failed, oldest first
+// used to pick jobs back up after a restart
+func (db *DB) GetUnfinishedExports() []Export {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	exports := []Export{}
+	for _, export := range db.dbstruct.Exports {
+		if export.Status == ExportPending || export.Status == ExportRunning {
+			exports = append(exports, export)
+		}
+	}
+	sort.Slice(exports, func(i, j int) bool {
+		return exports[i].Id < exports[j].Id
+	})
+
+	return exports
+}
+
+// PurgeExpiredExports removes the finished export jobs that completed before the cutoff
+// returns them, so their archives can be removed from disk
+func (db *DB) PurgeExpiredExports(cutoff time.Time) []Export {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	purged := []Export{}
+	for id, export := range db.dbstruct.Exports {
+		if export.Completed_at != nil && export.Completed_at.Before(cutoff) {
+			delete(db.dbstruct.Exports, id)
+			purged = append(purged, export)
+		}
+	}
+
+	if len(purged) > 0 {
+		db.writeDB()
+	}
+
+	return purged
+}
+
+// ExportUserData collects everything stored about a user
+func (db *DB) ExportUserData(userId int) (UserArchive, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return UserArchive{}, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	archive := UserArchive{
+		Generated_at: time.Now(),
+		Profile: ArchiveProfile{
+			Id:               user.Id,
+			Email:            user.Email,
+			Is_chirpy_red:    user.Is_chirpy_red,
+			Handle:           user.Handle,
+			Bio:              user.Bio,
+			Is_protected:     user.Is_protected,
+			Pinned_chirp_ids: user.Pinned_chirp_ids,
+			Deactivated_at:   user.Deactivated_at,
+			Suspended_at:     user.Suspended_at,
+		},
+		Chirps:    []Chirp{},
+		Likes:     []Like{},
+		Rechirps:  []Rechirp{},
+		Following: []Follow{},
+		Followers: []Follow{},
+
+		Notifications:           []Notification{},
+		NotificationPreferences: db.notificationPreferences(userId),
+
+		Conversations: []Conversation{},
+		Messages:      []Message{},
+
+		Blocks:  []Block{},
+		Mutes:   []Mute{},
+		Reports: []Report{},
+		Media:   []Media{},
+	}
+
+	for _, chirp := range db.dbstruct.Chirps {
+		if chirp.Author_id == userId {
+			archive.Chirps = append(archive.Chirps, chirp)
+		}
+	}
+	sort.Slice(archive.Chirps, func(i, j int) bool {
+		return archive.Chirps[i].Id < archive.Chirps[j].Id
+	})
+
+	for chirpId, likedAt := range db.likesByUser[userId] {
+		archive.Likes = append(archive.Likes, Like{User_id: userId, Chirp_id: chirpId, Liked_at: likedAt})
+	}
+	sortLikesByMostRecent(archive.Likes)
+
+	for _, rechirp := range db.dbstruct.Rechirps {
+		if rechirp.User_id == userId {
+			archive.Rechirps = append(archive.Rechirps, rechirp)
+		}
+	}
+	sort.Slice(archive.Rechirps, func(i, j int) bool {
+		return archive.Rechirps[i].Id < archive.Rechirps[j].Id
+	})
+
+	for followeeId, since := range db.dbstruct.Follows[userId] {
+		archive.Following = append(archive.Following, Follow{Follower_id: userId, Followee_id: followeeId, Since: since})
+	}
+	for followerId, since := range db.followersOf[userId] {
+		archive.Followers = append(archive.Followers, Follow{Follower_id: followerId, Followee_id: userId, Since: since})
+	}
+	sort.Slice(archive.Following, func(i, j int) bool {
+		return archive.Following[i].Since.Before(archive.Following[j].Since)
+	})
+	sort.Slice(archive.Followers, func(i, j int) bool {
+		return archive.Followers[i].Since.Before(archive.Followers[j].Since)
+	})
+	archive.Follow_requests = db.sentFollowRequests(userId)
+
+	for id := range db.notificationsByUser[userId] {
+		archive.Notifications = append(archive.Notifications, db.dbstruct.Notifications[id])
+	}
+	sort.Slice(archive.Notifications, func(i, j int) bool {
+		return archive.Notifications[i].Id < archive.Notifications[j].Id
+	})
+
+	for conversationId := range db.conversationsByUser[userId] {
+		archive.Conversations = append(archive.Conversations, db.dbstruct.Conversations[conversationId])
+		for _, messageId := range db.messagesByConversation[conversationId] {
+			if message := db.dbstruct.Messages[messageId]; message.Sender_id == userId {
+				archive.Messages = append(archive.Messages, message)
+			}
+		}
+	}
+	sort.Slice(archive.Conversations, func(i, j int) bool {
+		return archive.Conversations[i].Id < archive.Conversations[j].Id
+	})
+	sort.Slice(archive.Messages, func(i, j int) bool {
+		return archive.Messages[i].Id < archive.Messages[j].Id
+	})
+
+	for blockedId, since := range db.dbstruct.Blocks[userId] {
+		archive.Blocks = append(archive.Blocks, Block{Blocker_id: userId, Blocked_id: blockedId, Since: since})
+	}
+	for mutedId, since := range db.dbstruct.Mutes[userId] {
+		archive.Mutes = append(archive.Mutes, Mute{Muter_id: userId, Muted_id: mutedId, Since: since})
+	}
+	sort.Slice(archive.Blocks, func(i, j int) bool {
+		return archive.Blocks[i].Since.Before(archive.Blocks[j].Since)
+	})
+	sort.Slice(archive.Mutes, func(i, j int) bool {
+		return archive.Mutes[i].Since.Before(archive.Mutes[j].Since)
+	})
+
+	for _, report := range db.dbstruct.Reports {
+		if report.Reporter_id == userId {
+			archive.Reports = append(archive.Reports, report)
+		}
+	}
+	sort.Slice(archive.Reports, func(i, j int) bool {
+		return archive.Reports[i].Id < archive.Reports[j].Id
+	})
+
+	for _, media := range db.dbstruct.Media {
+		if media.Owner_id == userId {
+			archive.Media = append(archive.Media, media)
+		}
+	}
+	sort.Slice(archive.Media, func(i, j int) bool {
+		return archive.Media[i].Id < archive.Media[j].Id
+	})
+
+	archive.Poll_votes = db.votesBy(userId)
+
+	archive.Drafts = []Draft{}
+	for _, draft := range db.dbstruct.Drafts {
+		if draft.Author_id == userId {
+			archive.Drafts = append(archive.Drafts, draft)
+		}
+	}
+	sort.Slice(archive.Drafts, func(i, j int) bool {
+		return archive.Drafts[i].Id < archive.Drafts[j].Id
+	})
+
+	archive.Bookmarks = db.bookmarksOf(userId)
+	archive.Lists = db.listsOf(userId)
+	archive.List_members = []ListMember{}
+	for _, list := range archive.Lists {
+		for memberId, addedAt := range db.dbstruct.ListMembers[list.Id] {
+			archive.List_members = append(archive.List_members, ListMember{List_id: list.Id, User_id: memberId, Added_at: addedAt})
+		}
+	}
+	sort.Slice(archive.List_members, func(i, j int) bool {
+		if archive.List_members[i].List_id != archive.List_members[j].List_id {
+			return archive.List_members[i].List_id < archive.List_members[j].List_id
+		}
+		return archive.List_members[i].Added_at.Before(archive.List_members[j].Added_at)
+	})
+
+	archive.Dismissed_recommendations = []Dismissal{}
+	for dismissedId, dismissedAt := range db.dbstruct.DismissedRecommendations[userId] {
+		archive.Dismissed_recommendations = append(archive.Dismissed_recommendations,
+			Dismissal{User_id: userId, Dismissed_id: dismissedId, Dismissed_at: dismissedAt})
+	}
+	sort.Slice(archive.Dismissed_recommendations, func(i, j int) bool {
+		return archive.Dismissed_recommendations[i].Dismissed_at.Before(archive.Dismissed_recommendations[j].Dismissed_at)
+	})
+
+	return archive, nil
+}
@@ -0,0 +1,58 @@
+package database
+
+import (
+	"chirpy/contentfilter"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// reason of the reports the content filter files
+const ReportReasonContentFilter = "content_filter"
+
+// SetContentFilter sets the rules chirps, bios and messages are checked against
+// until it is called contentfilter.Default() is used
+func (db *DB) SetContentFilter(filter contentfilter.ContentFilter) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.filter = filter
+}
+
+// filterText runs user written text of the given kind through the content filter
+// returns the text with replacements made and the names of the flag rules it matched,
+// or an error wrapping contentfilter.ErrRejected if a rule rejected it
+// caller must hold a Reader or Writer lock
+func (db *DB) filterText(kind, text string) (string, []string, error) {
+	result := db.filter.Filter(kind, text)
+	if result.Rejected {
+		return text, nil, fmt.Errorf("%s %w (%s)", kind, contentfilter.ErrRejected, result.Rejected_by)
+	}
+	return result.Text, result.Flagged_by, nil
+}
+
+// fileFilterReport puts flagged content in the moderation queue
+// the report has no reporter, and there is only ever one open report per piece of content
+// used by CreateChirp, UpdateChirp, UpdateUser and SendMessage
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) fileFilterReport(report Report, flaggedBy []string) {
+	if len(flaggedBy) == 0 {
+		return
+	}
+
+	for _, existing := range db.dbstruct.Reports {
+		if existing.Status == ReportOpen && existing.Reporter_id == 0 && existing.Target_type == report.Target_type &&
+			existing.Chirp_id == report.Chirp_id && existing.Message_id == report.Message_id && existing.User_id == report.User_id {
+			return
+		}
+	}
+
+	report.Id = db.nextId("reports")
+	report.Reporter_id = 0
+	report.Reason = ReportReasonContentFilter
+	report.Details = "matched " + strings.Join(flaggedBy, ", ")
+	report.Status = ReportOpen
+	report.Created_at = time.Now()
+	db.dbstruct.Reports[report.Id] = report
+}
@@ -0,0 +1,157 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// what following a user led to
+const (
+	FollowStateFollowing = "following"
+	// the user is protected, they have to approve the follow first
+	FollowStateRequested = "requested"
+)
+
+// FollowRequest is a user asking to follow a protected user
+type FollowRequest struct {
+	Requester_id int       `json:"requester_id"`
+	Target_id    int       `json:"target_id"`
+	Requested_at time.Time `json:"requested_at"`
+}
+
+// used by UpdateUser when a user turns their protection off
+// makes everyone waiting for approval follow the user
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) approveFollowRequests(userId int) {
+	for requesterId, requests := range db.dbstruct.FollowRequests {
+		if _, ok := requests[userId]; ok {
+			db.removeFollowRequest(requesterId, userId)
+			db.addFollow(requesterId, userId, time.Now())
+		}
+	}
+}
+
+// IsProtected checks if a user approves their followers
+func (db *DB) IsProtected(userId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.dbstruct.Users[userId].Is_protected
+}
+
+// HasRequestedToFollow checks if the requester is waiting for the target to approve their follow
+func (db *DB) HasRequestedToFollow(requesterId, targetId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, ok := db.dbstruct.FollowRequests[requesterId][targetId]
+	return ok
+}
+
+// ApproveFollowRequest makes the requester follow the user who approved it
+func (db *DB) ApproveFollowRequest(targetId, requesterId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.FollowRequests[requesterId][targetId]; !ok {
+		return fmt.Errorf("no follow request from user with ID %d", requesterId)
+	}
+
+	db.removeFollowRequest(requesterId, targetId)
+	db.addFollow(requesterId, targetId, time.Now())
+	db.writeDB()
+
+	return nil
+}
+
+// RejectFollowRequest removes a follow request without following, the requester isn't told
+func (db *DB) RejectFollowRequest(targetId, requesterId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.FollowRequests[requesterId][targetId]; !ok {
+		return fmt.Errorf("no follow request from user with ID %d", requesterId)
+	}
+
+	db.removeFollowRequest(requesterId, targetId)
+	db.writeDB()
+
+	return nil
+}
+
+// GetFollowRequests returns the pending requests to follow a user, most recent first
+func (db *DB) GetFollowRequests(targetId, limit, offset int) []FollowRequest {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	requests := []FollowRequest{}
+	for requesterId, targets := range db.dbstruct.FollowRequests {
+		if requestedAt, ok := targets[targetId]; ok && !db.isUserDeactivated(requesterId) {
+			requests = append(requests, FollowRequest{Requester_id: requesterId, Target_id: targetId, Requested_at: requestedAt})
+		}
+	}
+	sortFollowRequestsByMostRecent(requests)
+
+	return paginate(requests, limit, offset)
+}
+
+// GetSentFollowRequests returns the requests a user made that are still pending, most recent first
+func (db *DB) GetSentFollowRequests(requesterId, limit, offset int) []FollowRequest {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return paginate(db.sentFollowRequests(requesterId), limit, offset)
+}
+
+// used by GetSentFollowRequests and ExportUserData
+// caller must hold a Reader or Writer lock
+func (db *DB) sentFollowRequests(requesterId int) []FollowRequest {
+	requests := []FollowRequest{}
+	for targetId, requestedAt := range db.dbstruct.FollowRequests[requesterId] {
+		if !db.isUserDeactivated(targetId) {
+			requests = append(requests, FollowRequest{Requester_id: requesterId, Target_id: targetId, Requested_at: requestedAt})
+		}
+	}
+	sortFollowRequestsByMostRecent(requests)
+	return requests
+}
+
+func sortFollowRequestsByMostRecent(requests []FollowRequest) {
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Requested_at.After(requests[j].Requested_at)
+	})
+}
+
+// isProtectedFrom checks if a user's chirps are hidden from the viewer because the user is protected
+// and the viewer isn't them or one of their followers
+// caller must hold a Reader or Writer lock
+func (db *DB) isProtectedFrom(userId, viewerId int) bool {
+	if !db.dbstruct.Users[userId].Is_protected || userId == viewerId {
+		return false
+	}
+	_, following := db.dbstruct.Follows[viewerId][userId]
+	return viewerId == 0 || !following
+}
+
+// caller must hold the Writer lock
+func (db *DB) addFollowRequest(requesterId, targetId int, requestedAt time.Time) {
+	if db.dbstruct.FollowRequests[requesterId] == nil {
+		db.dbstruct.FollowRequests[requesterId] = make(map[int]time.Time)
+	}
+	db.dbstruct.FollowRequests[requesterId][targetId] = requestedAt
+}
+
+// caller must hold the Writer lock
+func (db *DB) removeFollowRequest(requesterId, targetId int) {
+	delete(db.dbstruct.FollowRequests[requesterId], targetId)
+	if len(db.dbstruct.FollowRequests[requesterId]) == 0 {
+		delete(db.dbstruct.FollowRequests, requesterId)
+	}
+}
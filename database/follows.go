@@ -0,0 +1,173 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Follow is one user following another
+type Follow struct {
+	Follower_id int       `json:"follower_id"`
+	Followee_id int       `json:"followee_id"`
+	Since       time.Time `json:"since"`
+}
+
+// FollowUser makes the follower follow the followee, following someone twice does nothing
+// protected users get a follow request instead, see FollowStateRequested
+// returns FollowStateFollowing or FollowStateRequested, and whether the follow or request is new
+func (db *DB) FollowUser(followerId, followeeId int) (string, bool, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if followerId == followeeId {
+		return "", false, errors.New("you can't follow yourself")
+	}
+	followee, ok := db.dbstruct.Users[followeeId]
+	if !ok || followee.IsDeactivated() {
+		return "", false, fmt.Errorf("user with ID %d not found", followeeId)
+	}
+	if db.isBlocked(followerId, followeeId) {
+		return "", false, ErrBlocked
+	}
+
+	if _, ok := db.dbstruct.Follows[followerId][followeeId]; ok {
+		return FollowStateFollowing, false, nil
+	}
+
+	if followee.Is_protected {
+		if _, ok := db.dbstruct.FollowRequests[followerId][followeeId]; ok {
+			return FollowStateRequested, false, nil
+		}
+		db.addFollowRequest(followerId, followeeId, time.Now())
+		db.writeDB()
+		return FollowStateRequested, true, nil
+	}
+
+	db.addFollow(followerId, followeeId, time.Now())
+	db.writeDB()
+
+	return FollowStateFollowing, true, nil
+}
+
+// UnfollowUser stops the follower from following the followee, if they were
+// a pending follow request is withdrawn
+func (db *DB) UnfollowUser(followerId, followeeId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[followeeId]; !ok {
+		return fmt.Errorf("user with ID %d not found", followeeId)
+	}
+
+	if _, ok := db.dbstruct.Follows[followerId][followeeId]; ok {
+		db.removeFollow(followerId, followeeId)
+		db.writeDB()
+	}
+	if _, ok := db.dbstruct.FollowRequests[followerId][followeeId]; ok {
+		db.removeFollowRequest(followerId, followeeId)
+		db.writeDB()
+	}
+
+	return nil
+}
+
+// IsFollowing checks if the follower follows the followee
+func (db *DB) IsFollowing(followerId, followeeId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, ok := db.dbstruct.Follows[followerId][followeeId]
+	return ok
+}
+
+// GetFollowers returns the users following a user that the viewer can see, most recent follow first
+func (db *DB) GetFollowers(userId, viewerId, limit, offset int) ([]User, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok || db.isHiddenFrom(userId, viewerId) {
+		return nil, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	return db.usersFromFollowMap(db.followersOf[userId], viewerId, limit, offset), nil
+}
+
+// GetFollowing returns the users a user follows that the viewer can see, most recent follow first
+func (db *DB) GetFollowing(userId, viewerId, limit, offset int) ([]User, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok || db.isHiddenFrom(userId, viewerId) {
+		return nil, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	return db.usersFromFollowMap(db.dbstruct.Follows[userId], viewerId, limit, offset), nil
+}
+
+// turns a user id -> followed since map into a page of users the viewer can see, most recent first
+// also used for list members, which are stored the same way
+// caller must hold a Reader or Writer lock
+func (db *DB) usersFromFollowMap(follows map[int]time.Time, viewerId, limit, offset int) []User {
+	ids := []int{}
+	for id := range follows {
+		if !db.isHiddenFrom(id, viewerId) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return follows[ids[i]].After(follows[ids[j]])
+	})
+
+	users := []User{}
+	for _, id := range paginate(ids, limit, offset) {
+		users = append(users, db.dbstruct.Users[id])
+	}
+	return users
+}
+
+// addFollow stores a follow in both the stored follows and the followers index
+// caller must hold the Writer lock
+func (db *DB) addFollow(followerId, followeeId int, since time.Time) {
+	if db.dbstruct.Follows[followerId] == nil {
+		db.dbstruct.Follows[followerId] = make(map[int]time.Time)
+	}
+	db.dbstruct.Follows[followerId][followeeId] = since
+	if db.followersOf[followeeId] == nil {
+		db.followersOf[followeeId] = make(map[int]time.Time)
+	}
+	db.followersOf[followeeId][followerId] = since
+}
+
+// removeFollow deletes a follow from both the stored follows and the followers index
+// caller must hold the Writer lock
+func (db *DB) removeFollow(followerId, followeeId int) {
+	delete(db.dbstruct.Follows[followerId], followeeId)
+	if len(db.dbstruct.Follows[followerId]) == 0 {
+		delete(db.dbstruct.Follows, followerId)
+	}
+	delete(db.followersOf[followeeId], followerId)
+	if len(db.followersOf[followeeId]) == 0 {
+		delete(db.followersOf, followeeId)
+	}
+}
+
+// indexFollows builds the followers index from the stored follows
+// used by NewDB after loading the db
+func (db *DB) indexFollows() {
+	db.followersOf = make(map[int]map[int]time.Time)
+	for followerId, followees := range db.dbstruct.Follows {
+		for followeeId, since := range followees {
+			if db.followersOf[followeeId] == nil {
+				db.followersOf[followeeId] = make(map[int]time.Time)
+			}
+			db.followersOf[followeeId][followerId] = since
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package database
+
+import (
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+// default chirp length limits, in characters as counted by chirpLength
+const (
+	defaultChirpMaxLength    = 140
+	defaultRedChirpMaxLength = 280
+)
+
+// every link counts as this many characters, however long it really is
+const linkLength = 23
+
+var linkPattern = regexp.MustCompile(`https?://[^\s]+`)
+
+// a character takes at most this many bytes on average, so a text can't be made huge
+// with marks that stack onto a single character
+// the longest emoji, ZWJ sequences with skin tones like 👩🏽‍❤️‍💋‍👨🏿, take 35 bytes
+const maxBytesPerCharacter = 40
+
+// links can't be longer than this, however few characters they count for
+const maxLinkBytes = 2048
+
+// SetChirpLengthLimits sets how long chirps can be, for everyone and for Chirpy Red users
+func (db *DB) SetChirpLengthLimits(maxLength, redMaxLength int) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.chirpMaxLength = maxLength
+	db.redChirpMaxLength = redMaxLength
+}
+
+// chirpMaxLengthFor returns how long the user's chirps can be
+// caller must hold a Reader or Writer lock
+func (db *DB) chirpMaxLengthFor(userId int) int {
+	if db.dbstruct.Users[userId].Is_chirpy_red {
+		return db.redChirpMaxLength
+	}
+	return db.chirpMaxLength
+}
+
+// chirpLength is the length of a chirp body as users see it:
+// characters are counted as they are displayed, so an emoji or an accented letter is one no matter how many bytes it takes,
+// and links count as linkLength characters
+func chirpLength(body string) int {
+	length := 0
+	last := 0
+	for _, link := range linkPattern.FindAllStringIndex(body, -1) {
+		length += textLength(body[last:link[0]]) + linkLength
+		last = link[1]
+	}
+	return length + textLength(body[last:])
+}
+
+// isTooLong checks a text against a limit in characters as counted by textLength,
+// and against the hard limit of maxBytesPerCharacter bytes for each character
+func isTooLong(text string, maxLength int) bool {
+	return len(text) > maxLength*maxBytesPerCharacter || textLength(text) > maxLength
+}
+
+// isChirpTooLong is isTooLong for chirp bodies, links count as linkLength characters
+// and don't count toward the bytes limit, but each can be at most maxLinkBytes
+func isChirpTooLong(body string, maxLength int) bool {
+	textBytes := len(body)
+	for _, link := range linkPattern.FindAllStringIndex(body, -1) {
+		if link[1]-link[0] > maxLinkBytes {
+			return true
+		}
+		textBytes -= link[1] - link[0]
+	}
+	return textBytes > maxLength*maxBytesPerCharacter || chirpLength(body) > maxLength
+}
+
+// textLength counts the user perceived characters (grapheme clusters) in a text
+// follows the rules of Unicode Standard Annex #29 that matter for chat text:
+// combining marks, emoji with modifiers and ZWJ sequences, flags, and Hangul syllables each count as one
+func textLength(text string) int {
+	length := 0
+	var prev rune = -1
+	// whether the current cluster has an emoji, for ZWJ sequences
+	pictographic := false
+	// how many regional indicators in a row came right before, flags are pairs of them
+	regionalIndicators := 0
+
+	for len(text) > 0 {
+		r, size := utf8.DecodeRuneInString(text)
+		text = text[size:]
+
+		if prev != -1 && !isGraphemeBreak(prev, r, pictographic, regionalIndicators) {
+			if isRegionalIndicator(r) {
+				regionalIndicators++
+			}
+			pictographic = pictographic || isPictographic(r)
+			prev = r
+			continue
+		}
+
+		length++
+		pictographic = isPictographic(r)
+		regionalIndicators = 0
+		if isRegionalIndicator(r) {
+			regionalIndicators = 1
+		}
+		prev = r
+	}
+	return length
+}
+
+// whether a new character starts between prev and r
+func isGraphemeBreak(prev, r rune, pictographic bool, regionalIndicators int) bool {
+	switch {
+	case prev == '\r' && r == '\n':
+		return false
+	case prev == '\r' || prev == '\n' || r == '\r' || r == '\n':
+		return true
+	case isGraphemeExtend(r):
+		return false
+	case prev == '\u200d' && pictographic && isPictographic(r):
+		return false
+	case isRegionalIndicator(prev) && isRegionalIndicator(r):
+		return regionalIndicators%2 == 0
+	case isHangulLeading(prev):
+		return !(isHangulLeading(r) || isHangulVowel(r) || isHangulSyllable(r))
+	case isHangulVowel(prev) || isHangulLVSyllable(prev):
+		return !(isHangulVowel(r) || isHangulTrailing(r))
+	case isHangulTrailing(prev) || isHangulSyllable(prev):
+		return !isHangulTrailing(r)
+	}
+	return true
+}
+
+// characters that attach to the one before them
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == '\u200d' || // zero width joiner
+		(r >= 0xfe00 && r <= 0xfe0f) || (r >= 0xe0100 && r <= 0xe01ef) || // variation selectors
+		(r >= 0x1f3fb && r <= 0x1f3ff) || // emoji skin tones
+		(r >= 0xe0020 && r <= 0xe007f) // tags, used in subdivision flags
+}
+
+// emoji and other pictographs that can be joined into one with a ZWJ
+func isPictographic(r rune) bool {
+	return (r >= 0x1f000 && r <= 0x1faff && !isRegionalIndicator(r)) ||
+		(r >= 0x2600 && r <= 0x27bf) ||
+		(r >= 0x2300 && r <= 0x23ff) ||
+		(r >= 0x2b00 && r <= 0x2bff) ||
+		r == 0x00a9 || r == 0x00ae || r == 0x203c || r == 0x2049 || r == 0x2122
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+func isHangulLeading(r rune) bool {
+	return (r >= 0x1100 && r <= 0x115f) || (r >= 0xa960 && r <= 0xa97c)
+}
+
+func isHangulVowel(r rune) bool {
+	return (r >= 0x1160 && r <= 0x11a7) || (r >= 0xd7b0 && r <= 0xd7c6)
+}
+
+func isHangulTrailing(r rune) bool {
+	return (r >= 0x11a8 && r <= 0x11ff) || (r >= 0xd7cb && r <= 0xd7fb)
+}
+
+func isHangulSyllable(r rune) bool {
+	return r >= 0xac00 && r <= 0xd7a3
+}
+
+// syllables without a trailing consonant, another one can still follow
+func isHangulLVSyllable(r rune) bool {
+	return isHangulSyllable(r) && (r-0xac00)%28 == 0
+}
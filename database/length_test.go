@@ -0,0 +1,59 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIsTooLong(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		tooLong bool
+	}{
+		{"140 letters", strings.Repeat("a", 140), false},
+		{"141 letters", strings.Repeat("a", 141), true},
+		{"140 accented letters", strings.Repeat("é", 140), false},
+		{"140 Devanagari syllables", strings.Repeat("कि", 140), false},
+		{"140 thumbs up with a skin tone", strings.Repeat("👍🏽", 140), false},
+		{"140 flags", strings.Repeat("🇯🇵", 140), false},
+		{"140 families", strings.Repeat("👨‍👩‍👧‍👦", 140), false},
+		{"140 kisses with two skin tones", strings.Repeat("👩🏽‍❤️‍💋‍👨🏿", 140), false},
+		{"141 flags", strings.Repeat("🇯🇵", 141), true},
+		{"one letter with thousands of marks stacked on it", "e" + strings.Repeat("́", 5000), true},
+		{"140 characters with 20 marks stacked on each", strings.Repeat("e"+strings.Repeat("́", 20), 140), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isTooLong(test.text, 140); got != test.tooLong {
+				t.Errorf("isTooLong = %v, want %v (%d characters, %d bytes)", got, test.tooLong, textLength(test.text), len(test.text))
+			}
+		})
+	}
+}
+
+func TestIsChirpTooLong(t *testing.T) {
+	longLink := "https://example.com/" + strings.Repeat("a", 1000)
+
+	tests := []struct {
+		name    string
+		body    string
+		tooLong bool
+	}{
+		{"140 families", strings.Repeat("👨‍👩‍👧‍👦", 140), false},
+		{"a long link counts as 23 characters", strings.Repeat("a", 117) + longLink, false},
+		{"long links don't count toward the bytes", strings.Repeat("👍🏽", 90) + " " + longLink + " " + longLink, false},
+		{"too many characters around a link", strings.Repeat("a", 118) + longLink, true},
+		{"a link over 2048 bytes", "https://example.com/" + strings.Repeat("a", 2048), true},
+		{"marks stacked between links", "https://a.com " + "e" + strings.Repeat("́", 5000) + " https://b.com", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := isChirpTooLong(test.body, 140); got != test.tooLong {
+				t.Errorf("isChirpTooLong = %v, want %v (%d characters, %d bytes)", got, test.tooLong, chirpLength(test.body), len(test.body))
+			}
+		})
+	}
+}
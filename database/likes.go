@@ -0,0 +1,161 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Like is a single user liking a single chirp
+type Like struct {
+	User_id  int       `json:"user_id"`
+	Chirp_id int       `json:"chirp_id"`
+	Liked_at time.Time `json:"liked_at"`
+}
+
+// LikeChirp records a user liking a chirp, liking a chirp twice does nothing
+// returns the chirp's new like count and whether this was a new like
+func (db *DB) LikeChirp(userId, chirpId int) (int, bool, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, userId) {
+		return 0, false, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	if _, ok := db.dbstruct.Likes[chirpId][userId]; ok {
+		return len(db.dbstruct.Likes[chirpId]), false, nil
+	}
+
+	now := time.Now()
+	if db.dbstruct.Likes[chirpId] == nil {
+		db.dbstruct.Likes[chirpId] = make(map[int]time.Time)
+	}
+	db.dbstruct.Likes[chirpId][userId] = now
+	if db.likesByUser[userId] == nil {
+		db.likesByUser[userId] = make(map[int]time.Time)
+	}
+	db.likesByUser[userId][chirpId] = now
+	db.writeDB()
+
+	return len(db.dbstruct.Likes[chirpId]), true, nil
+}
+
+// UnlikeChirp removes a user's like from a chirp, if there is one
+// returns the chirp's new like count
+func (db *DB) UnlikeChirp(userId, chirpId int) (int, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Chirps[chirpId]; !ok {
+		return 0, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	if _, ok := db.dbstruct.Likes[chirpId][userId]; ok {
+		db.removeLike(userId, chirpId)
+		db.writeDB()
+	}
+
+	return len(db.dbstruct.Likes[chirpId]), nil
+}
+
+// GetLikeInfo returns how many likes a chirp has and whether the viewer is one of them
+// viewerId 0 means an anonymous viewer
+func (db *DB) GetLikeInfo(chirpId, viewerId int) (int, bool) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, liked := db.dbstruct.Likes[chirpId][viewerId]
+	return len(db.dbstruct.Likes[chirpId]), liked
+}
+
+// GetChirpLikers returns the users who liked a chirp that the viewer can see, most recent like first
+func (db *DB) GetChirpLikers(chirpId, viewerId, limit, offset int) ([]User, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, viewerId) {
+		return nil, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	likes := []Like{}
+	for userId, likedAt := range db.dbstruct.Likes[chirpId] {
+		if !db.isHiddenFrom(userId, viewerId) {
+			likes = append(likes, Like{User_id: userId, Chirp_id: chirpId, Liked_at: likedAt})
+		}
+	}
+	sortLikesByMostRecent(likes)
+
+	users := []User{}
+	for _, like := range paginate(likes, limit, offset) {
+		users = append(users, db.dbstruct.Users[like.User_id])
+	}
+
+	return users, nil
+}
+
+// GetLikedChirps returns the chirps a user liked that the viewer can see, most recent like first
+func (db *DB) GetLikedChirps(userId, viewerId, limit, offset int) ([]Chirp, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok || db.isHiddenFrom(userId, viewerId) {
+		return nil, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	likes := []Like{}
+	for chirpId, likedAt := range db.likesByUser[userId] {
+		if !db.isChirpHiddenFrom(db.dbstruct.Chirps[chirpId], viewerId) {
+			likes = append(likes, Like{User_id: userId, Chirp_id: chirpId, Liked_at: likedAt})
+		}
+	}
+	sortLikesByMostRecent(likes)
+
+	chirps := []Chirp{}
+	for _, like := range paginate(likes, limit, offset) {
+		chirps = append(chirps, db.dbstruct.Chirps[like.Chirp_id])
+	}
+
+	return chirps, nil
+}
+
+// removeLike deletes a like from both the stored likes and the per user index
+// caller must hold the Writer lock
+func (db *DB) removeLike(userId, chirpId int) {
+	delete(db.dbstruct.Likes[chirpId], userId)
+	if len(db.dbstruct.Likes[chirpId]) == 0 {
+		delete(db.dbstruct.Likes, chirpId)
+	}
+	delete(db.likesByUser[userId], chirpId)
+	if len(db.likesByUser[userId]) == 0 {
+		delete(db.likesByUser, userId)
+	}
+}
+
+// indexLikes builds the per user like index from the stored likes
+// used by NewDB after loading the db
+func (db *DB) indexLikes() {
+	db.likesByUser = make(map[int]map[int]time.Time)
+	for chirpId, likers := range db.dbstruct.Likes {
+		for userId, likedAt := range likers {
+			if db.likesByUser[userId] == nil {
+				db.likesByUser[userId] = make(map[int]time.Time)
+			}
+			db.likesByUser[userId][chirpId] = likedAt
+		}
+	}
+}
+
+// sorts likes by when they happened, newest first
+func sortLikesByMostRecent(likes []Like) {
+	sort.Slice(likes, func(i, j int) bool {
+		return likes[i].Liked_at.After(likes[j].Liked_at)
+	})
+}
@@ -0,0 +1,102 @@
+package database
+
+import "time"
+
+// how long link previews are kept before they are fetched again
+const (
+	linkPreviewMaxAge = 24 * time.Hour
+	// pages that couldn't be previewed are tried again sooner
+	linkPreviewRetryAfter = time.Hour
+)
+
+// LinkPreview is the card shown for a link in a chirp, shared by every chirp linking to the same url
+type LinkPreview struct {
+	Url         string `json:"url"`
+	Type        string `json:"type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Image_url   string `json:"image_url,omitempty"`
+	Site_name   string `json:"site_name,omitempty"`
+	// why the page couldn't be previewed, previews with an error aren't shown
+	Error      string    `json:"error,omitempty"`
+	Fetched_at time.Time `json:"fetched_at"`
+}
+
+// SaveLinkPreview stores the preview of a url, replacing the one fetched before
+func (db *DB) SaveLinkPreview(preview LinkPreview) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.dbstruct.LinkPreviews[preview.Url] = preview
+	db.writeDB()
+}
+
+// NeedsLinkPreview checks if a url has no preview yet, or one that is due to be fetched again
+func (db *DB) NeedsLinkPreview(url string) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	preview, ok := db.dbstruct.LinkPreviews[url]
+	if !ok {
+		return true
+	}
+	if preview.Error != "" {
+		return time.Since(preview.Fetched_at) > linkPreviewRetryAfter
+	}
+	return time.Since(preview.Fetched_at) > linkPreviewMaxAge
+}
+
+// GetLinkPreviews returns the previews of the links in a chirp, in the order they appear
+// links without a preview yet, or that couldn't be previewed, are left out
+func (db *DB) GetLinkPreviews(chirp Chirp) []LinkPreview {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	previews := []LinkPreview{}
+	seen := map[string]bool{}
+	for _, entity := range chirp.Entities {
+		if entity.Type != EntityLink || seen[entity.Text] {
+			continue
+		}
+		seen[entity.Text] = true
+		if preview, ok := db.dbstruct.LinkPreviews[entity.Text]; ok && preview.Error == "" {
+			previews = append(previews, preview)
+		}
+	}
+	return previews
+}
+
+// PurgeLinkPreviews removes the previews of urls no chirp links to anymore,
+// and failed ones that are due to be tried again, they aren't shown anyway
+// returns how many were removed
+func (db *DB) PurgeLinkPreviews() int {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	linked := map[string]bool{}
+	for _, chirp := range db.dbstruct.Chirps {
+		for _, entity := range chirp.Entities {
+			if entity.Type == EntityLink {
+				linked[entity.Text] = true
+			}
+		}
+	}
+
+	purged := 0
+	for url, preview := range db.dbstruct.LinkPreviews {
+		if !linked[url] || (preview.Error != "" && time.Since(preview.Fetched_at) > linkPreviewRetryAfter) {
+			delete(db.dbstruct.LinkPreviews, url)
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		db.writeDB()
+	}
+
+	return purged
+}
@@ -0,0 +1,39 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPurgeLinkPreviews(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db.dbstruct.Chirps[1] = Chirp{Id: 1, Entities: []Entity{
+		{Type: EntityLink, Text: "https://example.com/linked"},
+		{Type: EntityLink, Text: "https://example.com/failed"},
+		{Type: EntityLink, Text: "https://example.com/failed-recently"},
+	}}
+	db.SaveLinkPreview(LinkPreview{Url: "https://example.com/linked", Title: "Linked", Fetched_at: time.Now().Add(-48 * time.Hour)})
+	db.SaveLinkPreview(LinkPreview{Url: "https://example.com/unlinked", Title: "Unlinked", Fetched_at: time.Now()})
+	db.SaveLinkPreview(LinkPreview{Url: "https://example.com/failed", Error: "timeout", Fetched_at: time.Now().Add(-2 * time.Hour)})
+	db.SaveLinkPreview(LinkPreview{Url: "https://example.com/failed-recently", Error: "timeout", Fetched_at: time.Now()})
+
+	if purged := db.PurgeLinkPreviews(); purged != 2 {
+		t.Errorf("purged %d previews, want 2", purged)
+	}
+	for url, want := range map[string]bool{
+		// old previews stay as long as a chirp links to them, they are still shown
+		"https://example.com/linked":          true,
+		"https://example.com/unlinked":        false,
+		"https://example.com/failed":          false,
+		"https://example.com/failed-recently": true,
+	} {
+		if _, ok := db.dbstruct.LinkPreviews[url]; ok != want {
+			t.Errorf("preview of %s kept: %v, want %v", url, ok, want)
+		}
+	}
+}
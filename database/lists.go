@@ -0,0 +1,244 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// limits for lists
+const (
+	maxListsPerUser   = 100
+	maxListMembers    = 500
+	maxListNameLength = 25
+	maxListDescLength = 100
+)
+
+// List is a named group of users, with a timeline of just their chirps
+// lists are private, only their owner sees them
+type List struct {
+	Id          int       `json:"id"`
+	Owner_id    int       `json:"owner_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Created_at  time.Time `json:"created_at"`
+	Updated_at  time.Time `json:"updated_at"`
+}
+
+// ListMember is a user on a list
+type ListMember struct {
+	List_id  int       `json:"list_id"`
+	User_id  int       `json:"user_id"`
+	Added_at time.Time `json:"added_at"`
+}
+
+// SaveList creates a list, or renames one of the owner's lists if list.Id is set
+func (db *DB) SaveList(list List) (List, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	list.Name = strings.TrimSpace(list.Name)
+	list.Description = strings.TrimSpace(list.Description)
+	if list.Name == "" {
+		return List{}, errors.New("a list needs a name")
+	}
+	if isTooLong(list.Name, maxListNameLength) {
+		return List{}, fmt.Errorf("list names can be at most %d characters", maxListNameLength)
+	}
+	if isTooLong(list.Description, maxListDescLength) {
+		return List{}, fmt.Errorf("list descriptions can be at most %d characters", maxListDescLength)
+	}
+
+	now := time.Now()
+	if list.Id == 0 {
+		count := 0
+		for _, existing := range db.dbstruct.Lists {
+			if existing.Owner_id == list.Owner_id {
+				count++
+			}
+		}
+		if count >= maxListsPerUser {
+			return List{}, fmt.Errorf("you can have at most %d lists", maxListsPerUser)
+		}
+		list.Id = db.nextId("lists")
+		list.Created_at = now
+	} else {
+		existing, ok := db.dbstruct.Lists[list.Id]
+		if !ok || existing.Owner_id != list.Owner_id {
+			return List{}, fmt.Errorf("list with ID %d not found", list.Id)
+		}
+		list.Created_at = existing.Created_at
+	}
+	list.Updated_at = now
+
+	db.dbstruct.Lists[list.Id] = list
+	db.writeDB()
+
+	return list, nil
+}
+
+// GetList returns one of the owner's lists
+// other users' lists are reported as missing
+func (db *DB) GetList(listId, ownerId int) (List, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.ownList(listId, ownerId)
+}
+
+// GetLists returns the owner's lists, in the order they were created
+func (db *DB) GetLists(ownerId int) []List {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.listsOf(ownerId)
+}
+
+// DeleteList removes one of the owner's lists, the users on it aren't told
+func (db *DB) DeleteList(listId, ownerId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, err := db.ownList(listId, ownerId); err != nil {
+		return err
+	}
+
+	db.removeList(listId)
+	db.writeDB()
+
+	return nil
+}
+
+// AddListMember puts a user on one of the owner's lists, adding someone twice does nothing
+func (db *DB) AddListMember(listId, ownerId, userId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, err := db.ownList(listId, ownerId); err != nil {
+		return err
+	}
+	if _, ok := db.dbstruct.Users[userId]; !ok || db.isHiddenFrom(userId, ownerId) {
+		return fmt.Errorf("user with ID %d not found", userId)
+	}
+	if _, ok := db.dbstruct.ListMembers[listId][userId]; ok {
+		return nil
+	}
+	if len(db.dbstruct.ListMembers[listId]) >= maxListMembers {
+		return fmt.Errorf("a list can have at most %d users", maxListMembers)
+	}
+
+	if db.dbstruct.ListMembers[listId] == nil {
+		db.dbstruct.ListMembers[listId] = make(map[int]time.Time)
+	}
+	db.dbstruct.ListMembers[listId][userId] = time.Now()
+	db.writeDB()
+
+	return nil
+}
+
+// RemoveListMember takes a user off one of the owner's lists, if they are on it
+func (db *DB) RemoveListMember(listId, ownerId, userId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, err := db.ownList(listId, ownerId); err != nil {
+		return err
+	}
+
+	if _, ok := db.dbstruct.ListMembers[listId][userId]; ok {
+		db.removeListMember(listId, userId)
+		db.writeDB()
+	}
+
+	return nil
+}
+
+// GetListMemberCount returns how many users are on a list
+func (db *DB) GetListMemberCount(listId int) int {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return len(db.dbstruct.ListMembers[listId])
+}
+
+// GetListMembers returns the users on one of the owner's lists, most recently added first
+func (db *DB) GetListMembers(listId, ownerId, limit, offset int) ([]User, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, err := db.ownList(listId, ownerId); err != nil {
+		return nil, err
+	}
+
+	return db.usersFromFollowMap(db.dbstruct.ListMembers[listId], ownerId, limit, offset), nil
+}
+
+// GetListTimeline returns the timeline of one of the owner's lists, newest first:
+// the chirps by the users on it that the owner can see, and what those users rechirped
+// follows the same rules as GetTimeline
+func (db *DB) GetListTimeline(listId, ownerId, limit, offset int) ([]TimelineEntry, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, err := db.ownList(listId, ownerId); err != nil {
+		return nil, err
+	}
+
+	sources := map[int]bool{}
+	for userId := range db.dbstruct.ListMembers[listId] {
+		sources[userId] = true
+	}
+
+	return db.timelineFrom(sources, ownerId, limit, offset), nil
+}
+
+// looks up a list and makes sure it belongs to the owner
+// caller must hold a Reader or Writer lock
+func (db *DB) ownList(listId, ownerId int) (List, error) {
+	list, ok := db.dbstruct.Lists[listId]
+	if !ok || list.Owner_id != ownerId {
+		return List{}, fmt.Errorf("list with ID %d not found", listId)
+	}
+	return list, nil
+}
+
+// all of a user's lists, oldest first
+// used by GetLists and ExportUserData
+// caller must hold a Reader or Writer lock
+func (db *DB) listsOf(ownerId int) []List {
+	lists := []List{}
+	for _, list := range db.dbstruct.Lists {
+		if list.Owner_id == ownerId {
+			lists = append(lists, list)
+		}
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].Id < lists[j].Id
+	})
+	return lists
+}
+
+// caller must hold the Writer lock
+func (db *DB) removeList(listId int) {
+	delete(db.dbstruct.ListMembers, listId)
+	delete(db.dbstruct.Lists, listId)
+}
+
+// caller must hold the Writer lock
+func (db *DB) removeListMember(listId, userId int) {
+	delete(db.dbstruct.ListMembers[listId], userId)
+	if len(db.dbstruct.ListMembers[listId]) == 0 {
+		delete(db.dbstruct.ListMembers, listId)
+	}
+}
@@ -0,0 +1,145 @@
+package database
+
+import (
+	"chirpy/blobstore"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// how many images a chirp can have
+const MaxAttachments = 4
+
+// Media is an uploaded image, it can be attached to one chirp
+type Media struct {
+	Id           int    `json:"id"`
+	Owner_id     int    `json:"owner_id"`
+	Content_type string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int    `json:"size"` // bytes
+	// the chirp it is attached to, 0 until it is attached
+	Chirp_id   int       `json:"chirp_id,omitempty"`
+	Created_at time.Time `json:"created_at"`
+	// where the image and its thumbnail are in the blob store
+	Key                    string `json:"key"`
+	Thumbnail_key          string `json:"thumbnail_key"`
+	Thumbnail_content_type string `json:"thumbnail_content_type"`
+}
+
+// SetBlobStore sets where uploaded files are stored, so they can be removed along with their Media
+func (db *DB) SetBlobStore(blobs blobstore.BlobStore) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	db.blobs = blobs
+}
+
+// CreateMedia records an upload whose files are already in the blob store
+func (db *DB) CreateMedia(media Media) (Media, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[media.Owner_id]; !ok || db.isUserDeactivated(media.Owner_id) {
+		return Media{}, errors.New("user not found")
+	}
+
+	media.Id = db.nextId("media")
+	media.Chirp_id = 0
+	media.Created_at = time.Now()
+	db.dbstruct.Media[media.Id] = media
+	db.writeDB()
+
+	return media, nil
+}
+
+// GetMedia returns a SINGLE upload, if you know the id
+func (db *DB) GetMedia(id int) (Media, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	media, ok := db.dbstruct.Media[id]
+	if !ok {
+		return Media{}, fmt.Errorf("media with ID %d not found", id)
+	}
+
+	return media, nil
+}
+
+// CanSeeMedia checks if the viewer is allowed to see an upload
+// attached images can be seen by whoever can see their chirp, the rest only by the uploader
+// viewerId 0 means an anonymous viewer
+func (db *DB) CanSeeMedia(media Media, viewerId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if media.Chirp_id == 0 {
+		return viewerId != 0 && media.Owner_id == viewerId
+	}
+	chirp, ok := db.dbstruct.Chirps[media.Chirp_id]
+	return ok && !db.isChirpHiddenFrom(chirp, viewerId)
+}
+
+// PurgeUnattachedMedia removes uploads that weren't attached to a chirp before the cutoff
+// uploads waiting in a draft are kept
+// returns how many were removed
+func (db *DB) PurgeUnattachedMedia(cutoff time.Time) int {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	purged := 0
+	for id, media := range db.dbstruct.Media {
+		if media.Chirp_id == 0 && media.Created_at.Before(cutoff) && !db.isInDraft(id) {
+			db.removeMedia(id)
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		db.writeDB()
+	}
+
+	return purged
+}
+
+// checks that every upload can be attached to a new chirp by its author
+// used by CreateChirp and SaveDraft
+// caller must hold a Reader or Writer lock
+func (db *DB) checkAttachments(attachmentIds []int, authorId int) error {
+	if len(attachmentIds) > MaxAttachments {
+		return fmt.Errorf("a chirp can have at most %d attachments", MaxAttachments)
+	}
+
+	seen := map[int]bool{}
+	for _, id := range attachmentIds {
+		media, ok := db.dbstruct.Media[id]
+		if !ok || media.Owner_id != authorId {
+			return fmt.Errorf("media with ID %d not found", id)
+		}
+		if media.Chirp_id != 0 || seen[id] {
+			return fmt.Errorf("media with ID %d is already attached to a chirp", id)
+		}
+		seen[id] = true
+	}
+	return nil
+}
+
+// removeMedia deletes an upload along with its files
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) removeMedia(mediaId int) {
+	media := db.dbstruct.Media[mediaId]
+	if db.blobs != nil {
+		for _, key := range []string{media.Key, media.Thumbnail_key} {
+			if err := db.blobs.Delete(key); err != nil {
+				log.Printf("could not delete blob %s of media %d: %v", key, mediaId, err)
+			}
+		}
+	}
+	delete(db.dbstruct.Media, mediaId)
+}
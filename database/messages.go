@@ -0,0 +1,368 @@
+package database
+
+import (
+	"chirpy/contentfilter"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// limits for direct messages
+const (
+	maxMessageLength            = 1000
+	maxConversationParticipants = 50
+)
+
+// Conversation is a private thread of messages between two or more users
+type Conversation struct {
+	Id              int       `json:"id"`
+	Participant_ids []int     `json:"participant_ids"`
+	Created_at      time.Time `json:"created_at"`
+	Last_message_at time.Time `json:"last_message_at"`
+	// participant id -> id of the last message they read
+	Last_read map[int]int `json:"last_read"`
+}
+
+// Message is a single message sent to a conversation
+type Message struct {
+	Id              int       `json:"id"`
+	Conversation_id int       `json:"conversation_id"`
+	Sender_id       int       `json:"sender_id"`
+	Body            string    `json:"body"`
+	Created_at      time.Time `json:"created_at"`
+}
+
+// ConversationSummary is a conversation as listed for one of its participants
+type ConversationSummary struct {
+	Conversation
+	Last_message *Message `json:"last_message,omitempty"`
+	Unread_count int      `json:"unread_count"`
+}
+
+// IsParticipant reports whether a user is part of the conversation
+func (conversation Conversation) IsParticipant(userId int) bool {
+	for _, id := range conversation.Participant_ids {
+		if id == userId {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateConversation starts a conversation between the creator and the other participants
+// if those exact users already have a conversation, that one is returned instead,
+// along with false for not being new
+func (db *DB) CreateConversation(creatorId int, participantIds []int) (Conversation, bool, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// the creator is always a participant, everyone only once
+	seen := map[int]bool{creatorId: true}
+	participants := []int{creatorId}
+	for _, id := range participantIds {
+		if seen[id] {
+			continue
+		}
+		if _, ok := db.dbstruct.Users[id]; !ok || db.isUserDeactivated(id) {
+			return Conversation{}, false, fmt.Errorf("user with ID %d not found", id)
+		}
+		if !db.canMessage(creatorId, id) {
+			return Conversation{}, false, ErrBlocked
+		}
+		seen[id] = true
+		participants = append(participants, id)
+	}
+	if len(participants) < 2 {
+		return Conversation{}, false, errors.New("a conversation needs at least one other participant")
+	}
+	if len(participants) > maxConversationParticipants {
+		return Conversation{}, false, fmt.Errorf("a conversation can have at most %d participants", maxConversationParticipants)
+	}
+	sort.Ints(participants)
+
+	for conversationId := range db.conversationsByUser[creatorId] {
+		conversation := db.dbstruct.Conversations[conversationId]
+		if sameParticipants(conversation.Participant_ids, participants) {
+			return conversation, false, nil
+		}
+	}
+
+	now := time.Now()
+	conversation := Conversation{
+		Id:              db.nextId("conversations"),
+		Participant_ids: participants,
+		Created_at:      now,
+		Last_message_at: now,
+		Last_read:       make(map[int]int),
+	}
+	db.addConversation(conversation)
+	db.writeDB()
+
+	return conversation, true, nil
+}
+
+// SendMessage adds a message from one of the participants to a conversation
+// the sender has read everything up to their own message
+func (db *DB) SendMessage(conversationId, senderId int, body string) (Message, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	conversation, ok := db.dbstruct.Conversations[conversationId]
+	if !ok || !conversation.IsParticipant(senderId) {
+		return Message{}, fmt.Errorf("conversation with ID %d not found", conversationId)
+	}
+
+	if strings.TrimSpace(body) == "" {
+		return Message{}, errors.New("message is empty")
+	}
+	if isTooLong(body, maxMessageLength) {
+		return Message{}, errors.New("message is too long")
+	}
+	body, flaggedBy, err := db.filterText(contentfilter.KindMessage, body)
+	if err != nil {
+		return Message{}, err
+	}
+
+	// there has to be someone left to read it
+	recipients := 0
+	for _, id := range conversation.Participant_ids {
+		if id != senderId && !db.isUserDeactivated(id) && db.canMessage(senderId, id) {
+			recipients++
+		}
+	}
+	if recipients == 0 {
+		return Message{}, errors.New("nobody in this conversation can receive your message")
+	}
+
+	message := Message{
+		Id:              db.nextId("messages"),
+		Conversation_id: conversationId,
+		Sender_id:       senderId,
+		Body:            body,
+		Created_at:      time.Now(),
+	}
+	db.dbstruct.Messages[message.Id] = message
+	db.messagesByConversation[conversationId] = append(db.messagesByConversation[conversationId], message.Id)
+
+	conversation.Last_message_at = message.Created_at
+	conversation.Last_read[senderId] = message.Id
+	db.dbstruct.Conversations[conversationId] = conversation
+	db.fileFilterReport(Report{Target_type: ReportTargetMessage, Message_id: message.Id, User_id: senderId}, flaggedBy)
+	db.writeDB()
+
+	return message, nil
+}
+
+// GetMessage returns a SINGLE message, if you know the id
+// doesn't check who is asking, used for moderation
+func (db *DB) GetMessage(id int) (Message, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	message, ok := db.dbstruct.Messages[id]
+	if !ok {
+		return Message{}, fmt.Errorf("message with ID %d not found", id)
+	}
+
+	return message, nil
+}
+
+// GetConversation returns a SINGLE conversation, only to one of its participants
+func (db *DB) GetConversation(conversationId, userId int) (ConversationSummary, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	conversation, ok := db.dbstruct.Conversations[conversationId]
+	if !ok || !conversation.IsParticipant(userId) {
+		return ConversationSummary{}, fmt.Errorf("conversation with ID %d not found", conversationId)
+	}
+
+	return db.summarizeConversation(conversation, userId), nil
+}
+
+// GetConversations returns a user's conversations, most recently active first
+// also returns how many unread messages the user has in total
+func (db *DB) GetConversations(userId, limit, offset int) ([]ConversationSummary, int) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	summaries := []ConversationSummary{}
+	unreadCount := 0
+	for conversationId := range db.conversationsByUser[userId] {
+		summary := db.summarizeConversation(db.dbstruct.Conversations[conversationId], userId)
+		unreadCount += summary.Unread_count
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Last_message_at.Equal(summaries[j].Last_message_at) {
+			return summaries[i].Id > summaries[j].Id
+		}
+		return summaries[i].Last_message_at.After(summaries[j].Last_message_at)
+	})
+
+	return paginate(summaries, limit, offset), unreadCount
+}
+
+// GetMessages returns the messages in a conversation, newest first, only to one of its participants
+func (db *DB) GetMessages(conversationId, userId, limit, offset int) ([]Message, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	conversation, ok := db.dbstruct.Conversations[conversationId]
+	if !ok || !conversation.IsParticipant(userId) {
+		return nil, fmt.Errorf("conversation with ID %d not found", conversationId)
+	}
+
+	messages := []Message{}
+	ids := db.messagesByConversation[conversationId]
+	for i := len(ids) - 1; i >= 0; i-- {
+		message := db.dbstruct.Messages[ids[i]]
+		if db.isHiddenFrom(message.Sender_id, userId) {
+			continue
+		}
+		messages = append(messages, message)
+	}
+
+	return paginate(messages, limit, offset), nil
+}
+
+// MarkConversationRead marks every message in a conversation as read by the user
+func (db *DB) MarkConversationRead(conversationId, userId int) (ConversationSummary, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	conversation, ok := db.dbstruct.Conversations[conversationId]
+	if !ok || !conversation.IsParticipant(userId) {
+		return ConversationSummary{}, fmt.Errorf("conversation with ID %d not found", conversationId)
+	}
+
+	ids := db.messagesByConversation[conversationId]
+	if len(ids) > 0 && conversation.Last_read[userId] != ids[len(ids)-1] {
+		conversation.Last_read[userId] = ids[len(ids)-1]
+		db.dbstruct.Conversations[conversationId] = conversation
+		db.writeDB()
+	}
+
+	return db.summarizeConversation(conversation, userId), nil
+}
+
+// canMessage checks if a user is allowed to send messages to another user
+// caller must hold a Reader or Writer lock
+func (db *DB) canMessage(senderId, recipientId int) bool {
+	return !db.isUserDeactivated(senderId) && !db.isBlocked(senderId, recipientId)
+}
+
+// summarizeConversation adds the last message and the user's unread count to a conversation
+// caller must hold a Reader or Writer lock
+func (db *DB) summarizeConversation(conversation Conversation, userId int) ConversationSummary {
+	summary := ConversationSummary{Conversation: conversation}
+
+	// message ids only go up, so everything after the last read one is unread
+	ids := db.messagesByConversation[conversation.Id]
+	for i := len(ids) - 1; i >= 0; i-- {
+		message := db.dbstruct.Messages[ids[i]]
+		if db.isHiddenFrom(message.Sender_id, userId) {
+			continue
+		}
+		if summary.Last_message == nil {
+			summary.Last_message = &message
+		}
+		if message.Id <= conversation.Last_read[userId] {
+			break
+		}
+		if message.Sender_id != userId {
+			summary.Unread_count++
+		}
+	}
+
+	return summary
+}
+
+// addConversation stores a conversation in both the stored conversations and the per user index
+// caller must hold the Writer lock
+func (db *DB) addConversation(conversation Conversation) {
+	db.dbstruct.Conversations[conversation.Id] = conversation
+	for _, userId := range conversation.Participant_ids {
+		if db.conversationsByUser[userId] == nil {
+			db.conversationsByUser[userId] = make(map[int]bool)
+		}
+		db.conversationsByUser[userId][conversation.Id] = true
+	}
+}
+
+// removeFromConversations takes a deleted user out of all their conversations, along with their messages
+// conversations nobody is left in are deleted
+// caller must hold the Writer lock
+func (db *DB) removeFromConversations(userId int) {
+	for conversationId := range db.conversationsByUser[userId] {
+		conversation := db.dbstruct.Conversations[conversationId]
+
+		remaining := []int{}
+		for _, id := range conversation.Participant_ids {
+			if id != userId {
+				remaining = append(remaining, id)
+			}
+		}
+		conversation.Participant_ids = remaining
+		delete(conversation.Last_read, userId)
+
+		kept := []int{}
+		for _, messageId := range db.messagesByConversation[conversationId] {
+			if len(remaining) == 0 || db.dbstruct.Messages[messageId].Sender_id == userId {
+				delete(db.dbstruct.Messages, messageId)
+			} else {
+				kept = append(kept, messageId)
+			}
+		}
+
+		if len(remaining) == 0 {
+			delete(db.dbstruct.Conversations, conversationId)
+			delete(db.messagesByConversation, conversationId)
+			continue
+		}
+		db.dbstruct.Conversations[conversationId] = conversation
+		db.messagesByConversation[conversationId] = kept
+	}
+	delete(db.conversationsByUser, userId)
+}
+
+// indexConversations builds the per user conversation index and the per conversation message index
+// used by NewDB after loading the db
+func (db *DB) indexConversations() {
+	db.conversationsByUser = make(map[int]map[int]bool)
+	db.messagesByConversation = make(map[int][]int)
+	for _, conversation := range db.dbstruct.Conversations {
+		if conversation.Last_read == nil {
+			conversation.Last_read = make(map[int]int)
+		}
+		db.addConversation(conversation)
+	}
+	for _, message := range db.dbstruct.Messages {
+		db.messagesByConversation[message.Conversation_id] = append(db.messagesByConversation[message.Conversation_id], message.Id)
+	}
+	for _, ids := range db.messagesByConversation {
+		sort.Ints(ids)
+	}
+}
+
+// reports whether two sorted lists of user ids are the same
+func sameParticipants(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
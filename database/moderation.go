@@ -0,0 +1,341 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// what a Report is about
+const (
+	ReportTargetChirp = "chirp"
+	ReportTargetUser  = "user"
+	// only the content filter reports direct messages
+	ReportTargetMessage = "message"
+)
+
+// statuses a Report goes through
+const (
+	ReportOpen      = "open"
+	ReportResolved  = "resolved"
+	ReportDismissed = "dismissed"
+)
+
+// actions a moderator can take
+const (
+	ModerationHideChirp     = "hide_chirp"
+	ModerationUnhideChirp   = "unhide_chirp"
+	ModerationDeleteChirp   = "delete_chirp"
+	ModerationSuspendUser   = "suspend_user"
+	ModerationUnsuspendUser = "unsuspend_user"
+	ModerationDismiss       = "dismiss"
+)
+
+// reasons a user can give for a report
+var ReportReasons = []string{"spam", "harassment", "hate", "violence", "impersonation", "other"}
+
+const maxReportDetailsLength = 1000
+
+// Report is a user flagging a chirp or an account for the moderators
+// reports without a reporter were filed by the content filter
+type Report struct {
+	Id          int    `json:"id"`
+	Reporter_id int    `json:"reporter_id,omitempty"`
+	Target_type string `json:"target_type"`
+	// the reported chirp, for chirp reports
+	Chirp_id int `json:"chirp_id,omitempty"`
+	// the reported direct message, for message reports
+	Message_id int `json:"message_id,omitempty"`
+	// the reported user, or the author of the reported chirp or message
+	User_id     int        `json:"user_id"`
+	Reason      string     `json:"reason"`
+	Details     string     `json:"details,omitempty"`
+	Status      string     `json:"status"`
+	Created_at  time.Time  `json:"created_at"`
+	Resolved_at *time.Time `json:"resolved_at,omitempty"`
+	// id of the ModerationAction that closed the report
+	Action_id int `json:"action_id,omitempty"`
+}
+
+// ModerationAction is an entry in the moderation audit trail
+type ModerationAction struct {
+	Id        int    `json:"id"`
+	Action    string `json:"action"`
+	Moderator string `json:"moderator,omitempty"`
+	// the report that led to the action, if any
+	Report_id  int       `json:"report_id,omitempty"`
+	Chirp_id   int       `json:"chirp_id,omitempty"`
+	User_id    int       `json:"user_id,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// IsHidden reports whether a moderator hid the chirp
+func (chirp Chirp) IsHidden() bool {
+	return chirp.Hidden_at != nil
+}
+
+// IsSuspended reports whether a moderator suspended the user
+func (user User) IsSuspended() bool {
+	return user.Suspended_at != nil
+}
+
+// CreateReport files a report from a user
+// a user only has one open report per chirp or account, reporting it again returns that one
+// along with false for not being new
+func (db *DB) CreateReport(report Report) (Report, bool, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if !isReportReason(report.Reason) {
+		return Report{}, false, fmt.Errorf("reason must be one of %s", strings.Join(ReportReasons, ", "))
+	}
+	if isTooLong(report.Details, maxReportDetailsLength) {
+		return Report{}, false, errors.New("details are too long")
+	}
+
+	switch report.Target_type {
+	case ReportTargetChirp:
+		chirp, ok := db.dbstruct.Chirps[report.Chirp_id]
+		if !ok || db.isChirpHiddenFrom(chirp, report.Reporter_id) {
+			return Report{}, false, fmt.Errorf("chirp with ID %d not found", report.Chirp_id)
+		}
+		report.User_id = chirp.Author_id
+	case ReportTargetUser:
+		report.Chirp_id = 0
+		if _, ok := db.dbstruct.Users[report.User_id]; !ok || db.isUserDeactivated(report.User_id) {
+			return Report{}, false, fmt.Errorf("user with ID %d not found", report.User_id)
+		}
+	default:
+		return Report{}, false, errors.New("you can only report chirps and users")
+	}
+	if report.User_id == report.Reporter_id {
+		return Report{}, false, errors.New("you can't report yourself")
+	}
+
+	for _, existing := range db.dbstruct.Reports {
+		if existing.Status == ReportOpen && existing.Reporter_id == report.Reporter_id &&
+			existing.Target_type == report.Target_type && existing.Chirp_id == report.Chirp_id && existing.User_id == report.User_id {
+			return existing, false, nil
+		}
+	}
+
+	report.Id = db.nextId("reports")
+	report.Status = ReportOpen
+	report.Created_at = time.Now()
+	report.Resolved_at = nil
+	report.Action_id = 0
+	db.dbstruct.Reports[report.Id] = report
+	db.writeDB()
+
+	return report, true, nil
+}
+
+// GetReport returns a SINGLE report, if you know the id
+func (db *DB) GetReport(id int) (Report, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	report, ok := db.dbstruct.Reports[id]
+	if !ok {
+		return Report{}, fmt.Errorf("report with ID %d not found", id)
+	}
+
+	return report, nil
+}
+
+// GetReports returns the moderation queue: reports with the given status, oldest first
+// an empty status returns reports of every status
+// also returns the total number of matching reports
+func (db *DB) GetReports(status string, limit, offset int) ([]Report, int) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	reports := []Report{}
+	for _, report := range db.dbstruct.Reports {
+		if status == "" || report.Status == status {
+			reports = append(reports, report)
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Id < reports[j].Id
+	})
+
+	return paginate(reports, limit, offset), len(reports)
+}
+
+// ModerateReport takes an action on the chirp or user of a report and closes it
+// every other open report about the same chirp (or, for account actions, the same user) is closed along with it
+// the action is recorded in the audit trail
+func (db *DB) ModerateReport(reportId int, action ModerationAction) (ModerationAction, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	report, ok := db.dbstruct.Reports[reportId]
+	if !ok {
+		return ModerationAction{}, fmt.Errorf("report with ID %d not found", reportId)
+	}
+	if report.Status != ReportOpen {
+		return ModerationAction{}, fmt.Errorf("report is already %s", report.Status)
+	}
+
+	action.Report_id = reportId
+	action.User_id = report.User_id
+	action.Chirp_id = report.Chirp_id
+	switch action.Action {
+	case ModerationHideChirp, ModerationDeleteChirp:
+		if report.Target_type != ReportTargetChirp {
+			return ModerationAction{}, fmt.Errorf("%s only works on chirp reports", action.Action)
+		}
+	case ModerationSuspendUser:
+		// suspending is about the account, not the chirp that was reported
+		action.Chirp_id = 0
+	case ModerationDismiss:
+	default:
+		return ModerationAction{}, errors.New("action must be one of hide_chirp, delete_chirp, suspend_user or dismiss")
+	}
+
+	action, err := db.applyModerationAction(action)
+	if err != nil {
+		return ModerationAction{}, err
+	}
+
+	// close the report and everything else reported about the same thing
+	status := ReportResolved
+	if action.Action == ModerationDismiss {
+		status = ReportDismissed
+	}
+	for id, other := range db.dbstruct.Reports {
+		if other.Status != ReportOpen {
+			continue
+		}
+		sameTarget := other.Target_type == report.Target_type && other.Chirp_id == report.Chirp_id &&
+			other.Message_id == report.Message_id && other.User_id == report.User_id
+		if action.Action == ModerationSuspendUser {
+			sameTarget = other.User_id == report.User_id
+		}
+		if id == reportId || (sameTarget && action.Action != ModerationDismiss) {
+			other.Status = status
+			other.Resolved_at = &action.Created_at
+			other.Action_id = action.Id
+			db.dbstruct.Reports[id] = other
+		}
+	}
+	db.writeDB()
+
+	return action, nil
+}
+
+// Moderate takes an action that isn't tied to a report, like undoing an earlier one
+// the action is recorded in the audit trail
+func (db *DB) Moderate(action ModerationAction) (ModerationAction, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	action.Report_id = 0
+	action, err := db.applyModerationAction(action)
+	if err != nil {
+		return ModerationAction{}, err
+	}
+	db.writeDB()
+
+	return action, nil
+}
+
+// GetModerationActions returns the audit trail, newest first
+func (db *DB) GetModerationActions(limit, offset int) []ModerationAction {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	actions := []ModerationAction{}
+	for _, action := range db.dbstruct.ModerationActions {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Id > actions[j].Id
+	})
+
+	return paginate(actions, limit, offset)
+}
+
+// CanSeeChirp checks if the viewer is allowed to see a chirp
+// hidden chirps are only visible to their author, followers-only chirps to the author and their followers
+// viewerId 0 means an anonymous viewer
+func (db *DB) CanSeeChirp(chirp Chirp, viewerId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return !db.isChirpHiddenFrom(chirp, viewerId)
+}
+
+// applies a moderation action and adds it to the audit trail
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) applyModerationAction(action ModerationAction) (ModerationAction, error) {
+	now := time.Now()
+
+	switch action.Action {
+	case ModerationHideChirp, ModerationUnhideChirp, ModerationDeleteChirp:
+		chirp, ok := db.dbstruct.Chirps[action.Chirp_id]
+		if !ok {
+			return ModerationAction{}, fmt.Errorf("chirp with ID %d not found", action.Chirp_id)
+		}
+		action.User_id = chirp.Author_id
+		switch action.Action {
+		case ModerationHideChirp:
+			chirp.Hidden_at = &now
+			db.dbstruct.Chirps[chirp.Id] = chirp
+		case ModerationUnhideChirp:
+			chirp.Hidden_at = nil
+			db.dbstruct.Chirps[chirp.Id] = chirp
+		case ModerationDeleteChirp:
+			db.deleteChirp(chirp.Id)
+		}
+	case ModerationSuspendUser, ModerationUnsuspendUser:
+		user, ok := db.dbstruct.Users[action.User_id]
+		if !ok {
+			return ModerationAction{}, fmt.Errorf("user with ID %d not found", action.User_id)
+		}
+		if action.Action == ModerationSuspendUser {
+			user.Suspended_at = &now
+		} else {
+			user.Suspended_at = nil
+		}
+		db.dbstruct.Users[user.Id] = user
+	case ModerationDismiss:
+	default:
+		return ModerationAction{}, fmt.Errorf("unknown moderation action %q", action.Action)
+	}
+
+	action.Id = db.nextId("moderation_actions")
+	action.Created_at = now
+	db.dbstruct.ModerationActions[action.Id] = action
+
+	return action, nil
+}
+
+// isChirpHiddenFrom checks if a chirp is hidden from the viewer,
+// because the viewer can't see its author, a moderator hid it, or it is only for the author's followers
+// (because of its visibility or because the author is protected)
+// caller must hold a Reader or Writer lock
+func (db *DB) isChirpHiddenFrom(chirp Chirp, viewerId int) bool {
+	return db.isHiddenFrom(chirp.Author_id, viewerId) || (chirp.IsHidden() && chirp.Author_id != viewerId) ||
+		db.isVisibilityHiddenFrom(chirp, viewerId) || db.isProtectedFrom(chirp.Author_id, viewerId)
+}
+
+func isReportReason(reason string) bool {
+	for _, r := range ReportReasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// types of Notification
+const (
+	NotificationMention = "mention"
+	NotificationReply   = "reply"
+	NotificationLike    = "like"
+	NotificationFollow  = "follow"
+	// someone asked to follow a protected user, it goes with the Follow preference
+	NotificationFollowRequest = "follow_request"
+)
+
+// Notification tells a user that someone interacted with them
+type Notification struct {
+	Id      int    `json:"id"`
+	User_id int    `json:"user_id"` // who gets notified
+	Type    string `json:"type"`
+	// who did it
+	Actor_id int `json:"actor_id"`
+	// the chirp it's about, not set for follows
+	Chirp_id   int       `json:"chirp_id,omitempty"`
+	Created_at time.Time `json:"created_at"`
+	Read       bool      `json:"read"`
+}
+
+// NotificationPreferences are the types of notifications a user wants to get
+type NotificationPreferences struct {
+	Mention bool `json:"mention"`
+	Reply   bool `json:"reply"`
+	Like    bool `json:"like"`
+	Follow  bool `json:"follow"`
+}
+
+// every type of notification is on until the user turns it off
+var defaultNotificationPreferences = NotificationPreferences{
+	Mention: true,
+	Reply:   true,
+	Like:    true,
+	Follow:  true,
+}
+
+// wants reports whether the preferences allow the given type of notification
+func (prefs NotificationPreferences) wants(notificationType string) bool {
+	switch notificationType {
+	case NotificationMention:
+		return prefs.Mention
+	case NotificationReply:
+		return prefs.Reply
+	case NotificationLike:
+		return prefs.Like
+	case NotificationFollow, NotificationFollowRequest:
+		return prefs.Follow
+	}
+	return false
+}
+
+// CreateNotification stores a notification for its user
+// nothing is stored, and false returned, if the user turned that type off,
+// doesn't exist anymore, is the one who did it, or blocked or muted whoever did it
+func (db *DB) CreateNotification(notification Notification) (Notification, bool) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if notification.User_id == notification.Actor_id {
+		return notification, false
+	}
+	if _, ok := db.dbstruct.Users[notification.User_id]; !ok || db.isUserDeactivated(notification.User_id) {
+		return notification, false
+	}
+	if !db.notificationPreferences(notification.User_id).wants(notification.Type) {
+		return notification, false
+	}
+	if db.isMutedOrHidden(notification.Actor_id, notification.User_id) {
+		return notification, false
+	}
+	// e.g. mentions in followers-only chirps of users who don't follow the author
+	if chirp, ok := db.dbstruct.Chirps[notification.Chirp_id]; ok && db.isChirpHiddenFrom(chirp, notification.User_id) {
+		return notification, false
+	}
+
+	notification.Id = db.nextId("notifications")
+	notification.Read = false
+	if notification.Created_at.IsZero() {
+		notification.Created_at = time.Now()
+	}
+	db.addNotification(notification)
+	db.writeDB()
+
+	return notification, true
+}
+
+// GetNotifications returns a user's notifications, newest first, optionally only the unread ones
+// notifications from users the user blocked or muted since are left out
+// also returns how many unread notifications the user has in total
+func (db *DB) GetNotifications(userId int, unreadOnly bool, limit, offset int) ([]Notification, int) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	notifications := []Notification{}
+	unreadCount := 0
+	for id := range db.notificationsByUser[userId] {
+		notification := db.dbstruct.Notifications[id]
+		if db.isMutedOrHidden(notification.Actor_id, userId) {
+			continue
+		}
+		if !notification.Read {
+			unreadCount++
+		}
+		if unreadOnly && notification.Read {
+			continue
+		}
+		notifications = append(notifications, notification)
+	}
+	sort.Slice(notifications, func(i, j int) bool {
+		return notifications[i].Id > notifications[j].Id
+	})
+
+	return paginate(notifications, limit, offset), unreadCount
+}
+
+// MarkNotificationRead marks one of a user's notifications as read
+func (db *DB) MarkNotificationRead(userId, notificationId int) (Notification, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	notification, ok := db.dbstruct.Notifications[notificationId]
+	if !ok || notification.User_id != userId {
+		return Notification{}, fmt.Errorf("notification with ID %d not found", notificationId)
+	}
+
+	if !notification.Read {
+		notification.Read = true
+		db.dbstruct.Notifications[notificationId] = notification
+		db.writeDB()
+	}
+
+	return notification, nil
+}
+
+// MarkAllNotificationsRead marks all of a user's notifications as read
+// returns how many were unread
+func (db *DB) MarkAllNotificationsRead(userId int) int {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	marked := 0
+	for id := range db.notificationsByUser[userId] {
+		notification := db.dbstruct.Notifications[id]
+		if !notification.Read {
+			notification.Read = true
+			db.dbstruct.Notifications[id] = notification
+			marked++
+		}
+	}
+
+	if marked > 0 {
+		db.writeDB()
+	}
+
+	return marked
+}
+
+// GetNotificationPreferences returns the types of notifications a user wants
+func (db *DB) GetNotificationPreferences(userId int) NotificationPreferences {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.notificationPreferences(userId)
+}
+
+// UpdateNotificationPreferences saves the types of notifications a user wants
+func (db *DB) UpdateNotificationPreferences(userId int, prefs NotificationPreferences) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok {
+		return fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	db.dbstruct.NotificationPreferences[userId] = prefs
+	db.writeDB()
+
+	return nil
+}
+
+// caller must hold a Reader or Writer lock
+func (db *DB) notificationPreferences(userId int) NotificationPreferences {
+	if prefs, ok := db.dbstruct.NotificationPreferences[userId]; ok {
+		return prefs
+	}
+	return defaultNotificationPreferences
+}
+
+// addNotification stores a notification in both the stored notifications and the per user index
+// caller must hold the Writer lock
+func (db *DB) addNotification(notification Notification) {
+	db.dbstruct.Notifications[notification.Id] = notification
+	if db.notificationsByUser[notification.User_id] == nil {
+		db.notificationsByUser[notification.User_id] = make(map[int]bool)
+	}
+	db.notificationsByUser[notification.User_id][notification.Id] = true
+}
+
+// removeNotification deletes a notification from both the stored notifications and the per user index
+// caller must hold the Writer lock
+func (db *DB) removeNotification(notificationId int) {
+	notification := db.dbstruct.Notifications[notificationId]
+	delete(db.dbstruct.Notifications, notificationId)
+	delete(db.notificationsByUser[notification.User_id], notificationId)
+	if len(db.notificationsByUser[notification.User_id]) == 0 {
+		delete(db.notificationsByUser, notification.User_id)
+	}
+}
+
+// indexNotifications builds the per user notification index from the stored notifications
+// used by NewDB after loading the db
+func (db *DB) indexNotifications() {
+	db.notificationsByUser = make(map[int]map[int]bool)
+	for _, notification := range db.dbstruct.Notifications {
+		if db.notificationsByUser[notification.User_id] == nil {
+			db.notificationsByUser[notification.User_id] = make(map[int]bool)
+		}
+		db.notificationsByUser[notification.User_id][notification.Id] = true
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+)
+
+// how many chirps a user can pin to their profile at once
+const MaxPinnedChirps = 3
+
+// ErrTooManyPins is returned when pinning a chirp while MaxPinnedChirps are already pinned
+var ErrTooManyPins = fmt.Errorf("you can pin at most %d chirps, unpin one first", MaxPinnedChirps)
+
+// PinChirp pins one of the user's own chirps to the top of their profile, pinning a chirp twice does nothing
+// the most recently pinned chirp comes first
+// returns the ids of the user's pinned chirps
+func (db *DB) PinChirp(userId, chirpId int) ([]int, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, userId) {
+		return nil, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+	if chirp.Author_id != userId {
+		return nil, ErrNotAuthor
+	}
+
+	if isPinned(user, chirpId) {
+		return user.Pinned_chirp_ids, nil
+	}
+	if len(user.Pinned_chirp_ids) >= MaxPinnedChirps {
+		return nil, ErrTooManyPins
+	}
+
+	user.Pinned_chirp_ids = append([]int{chirpId}, user.Pinned_chirp_ids...)
+	db.dbstruct.Users[userId] = user
+	db.writeDB()
+
+	return user.Pinned_chirp_ids, nil
+}
+
+// UnpinChirp takes a chirp off the user's profile, if it was pinned
+// returns the ids of the user's pinned chirps
+func (db *DB) UnpinChirp(userId, chirpId int) ([]int, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return nil, errors.New("user not found")
+	}
+	if _, ok := db.dbstruct.Chirps[chirpId]; !ok {
+		return nil, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	if isPinned(user, chirpId) {
+		db.unpinChirp(userId, chirpId)
+		db.writeDB()
+	}
+
+	return db.dbstruct.Users[userId].Pinned_chirp_ids, nil
+}
+
+// IsPinned checks if a chirp is pinned to its author's profile
+func (db *DB) IsPinned(chirp Chirp) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return isPinned(db.dbstruct.Users[chirp.Author_id], chirp.Id)
+}
+
+// GetPinnedChirps returns the chirps a user pinned that the viewer can see, most recently pinned first
+func (db *DB) GetPinnedChirps(userId, viewerId int) []Chirp {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.pinnedChirps(userId, viewerId)
+}
+
+// used by GetPinnedChirps, GetChirpsByAuthor and GetProfile
+// caller must hold a Reader or Writer lock
+func (db *DB) pinnedChirps(userId, viewerId int) []Chirp {
+	chirps := []Chirp{}
+	for _, chirpId := range db.dbstruct.Users[userId].Pinned_chirp_ids {
+		if chirp, ok := db.dbstruct.Chirps[chirpId]; ok && !db.isChirpHiddenFrom(chirp, viewerId) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps
+}
+
+func isPinned(user User, chirpId int) bool {
+	for _, id := range user.Pinned_chirp_ids {
+		if id == chirpId {
+			return true
+		}
+	}
+	return false
+}
+
+// removes a chirp from its author's pins, used when the chirp is unpinned or deleted
+// caller must hold the Writer lock
+func (db *DB) unpinChirp(userId, chirpId int) {
+	user, ok := db.dbstruct.Users[userId]
+	if !ok {
+		return
+	}
+	pinned := []int{}
+	for _, id := range user.Pinned_chirp_ids {
+		if id != chirpId {
+			pinned = append(pinned, id)
+		}
+	}
+	if len(pinned) == 0 {
+		pinned = nil
+	}
+	user.Pinned_chirp_ids = pinned
+	db.dbstruct.Users[userId] = user
+}
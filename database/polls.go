@@ -0,0 +1,220 @@
+package database
+
+import (
+	"chirpy/contentfilter"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// limits for polls
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 25
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+	// how long a poll runs if its chirp doesn't say
+	defaultPollDuration = 24 * time.Hour
+)
+
+// when voters get to see how a poll is going
+const (
+	PollResultsAfterVote  = "after_vote"  // once they voted, or once the poll closed
+	PollResultsAfterClose = "after_close" // only once the poll closed
+)
+
+// returned when voting on a poll that is over
+var ErrPollClosed = errors.New("poll is closed")
+
+// returned when voting on a poll a second time
+var ErrAlreadyVoted = errors.New("you already voted on this poll")
+
+// Poll is a question attached to a chirp, the chirp's body asks it
+type Poll struct {
+	Options    []string  `json:"options"`
+	Expires_at time.Time `json:"expires_at"`
+	// PollResultsAfterVote or PollResultsAfterClose
+	Results_visibility string `json:"results_visibility"`
+	// only used when creating the chirp, in minutes, Expires_at is set from it
+	Duration_minutes int `json:"duration_minutes,omitempty"`
+}
+
+// PollVote is a user's vote, as handed to them in a data export
+type PollVote struct {
+	Chirp_id int `json:"chirp_id"`
+	Option   int `json:"option"`
+}
+
+// PollTally is how a poll is going, as seen by one viewer
+type PollTally struct {
+	Closed bool
+	// false when the viewer isn't allowed to see the results yet, Counts and Total are left empty then
+	Results_visible bool
+	// votes per option, in the order of the options
+	Counts []int
+	Total  int
+	// the option the viewer voted for, -1 if they didn't
+	Voted_option int
+}
+
+// IsClosed reports whether a poll stopped taking votes
+func (poll Poll) IsClosed(now time.Time) bool {
+	return !now.Before(poll.Expires_at)
+}
+
+// VotePoll records a user's vote on the poll of a chirp
+// everyone gets one vote, it can't be changed
+func (db *DB) VotePoll(chirpId, userId, option int) (PollTally, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, userId) || chirp.IsHidden() {
+		return PollTally{}, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+	if chirp.Poll == nil {
+		return PollTally{}, errors.New("chirp doesn't have a poll")
+	}
+	if chirp.Poll.IsClosed(time.Now()) {
+		return PollTally{}, ErrPollClosed
+	}
+	if option < 0 || option >= len(chirp.Poll.Options) {
+		return PollTally{}, fmt.Errorf("option must be between 0 and %d", len(chirp.Poll.Options)-1)
+	}
+	if _, voted := db.dbstruct.PollVotes[chirpId][userId]; voted {
+		return PollTally{}, ErrAlreadyVoted
+	}
+
+	if db.dbstruct.PollVotes[chirpId] == nil {
+		db.dbstruct.PollVotes[chirpId] = make(map[int]int)
+	}
+	db.dbstruct.PollVotes[chirpId][userId] = option
+	db.writeDB()
+
+	return db.pollTally(chirp, userId), nil
+}
+
+// GetPollTally returns how the poll of a chirp is going, as far as the viewer is allowed to know
+// viewerId 0 means an anonymous viewer
+func (db *DB) GetPollTally(chirp Chirp, viewerId int) PollTally {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.pollTally(chirp, viewerId)
+}
+
+// the author always sees the results, everyone else once the poll's results_visibility allows it
+// caller must hold a Reader or Writer lock
+func (db *DB) pollTally(chirp Chirp, viewerId int) PollTally {
+	if chirp.Poll == nil {
+		return PollTally{}
+	}
+
+	votes := db.dbstruct.PollVotes[chirp.Id]
+	tally := PollTally{Closed: chirp.Poll.IsClosed(time.Now()), Voted_option: -1}
+	if option, ok := votes[viewerId]; ok && viewerId != 0 {
+		tally.Voted_option = option
+	}
+
+	switch {
+	case tally.Closed, viewerId != 0 && viewerId == chirp.Author_id:
+		tally.Results_visible = true
+	case chirp.Poll.Results_visibility == PollResultsAfterVote:
+		tally.Results_visible = tally.Voted_option != -1
+	}
+	if !tally.Results_visible {
+		return tally
+	}
+
+	tally.Counts = make([]int, len(chirp.Poll.Options))
+	for _, option := range votes {
+		if option >= len(tally.Counts) {
+			continue
+		}
+		tally.Counts[option]++
+		tally.Total++
+	}
+	return tally
+}
+
+// checks and fills in the poll of a new chirp
+// options go through the content filter like the chirp's body
+// returns the names of the flag rules the options matched
+// used by CreateChirp
+// caller must hold a Reader or Writer lock
+func (db *DB) preparePoll(poll *Poll, now time.Time) ([]string, error) {
+	if len(poll.Options) < minPollOptions || len(poll.Options) > maxPollOptions {
+		return nil, fmt.Errorf("a poll needs %d to %d options", minPollOptions, maxPollOptions)
+	}
+
+	flaggedBy := []string{}
+	seen := map[string]bool{}
+	for i, option := range poll.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || isTooLong(option, maxPollOptionLength) {
+			return nil, fmt.Errorf("poll options must be 1 to %d characters", maxPollOptionLength)
+		}
+		if seen[strings.ToLower(option)] {
+			return nil, errors.New("poll options must be different from each other")
+		}
+		seen[strings.ToLower(option)] = true
+
+		filtered, flags, err := db.filterText(contentfilter.KindChirp, option)
+		if err != nil {
+			return nil, err
+		}
+		poll.Options[i] = filtered
+		flaggedBy = append(flaggedBy, flags...)
+	}
+
+	duration := defaultPollDuration
+	if poll.Duration_minutes != 0 {
+		duration = time.Duration(poll.Duration_minutes) * time.Minute
+	}
+	if duration < minPollDuration || duration > maxPollDuration {
+		return nil, fmt.Errorf("a poll can run for %d minutes to %d days", int(minPollDuration.Minutes()), int(maxPollDuration.Hours()/24))
+	}
+	poll.Expires_at = now.Add(duration)
+	poll.Duration_minutes = 0
+
+	switch poll.Results_visibility {
+	case "":
+		poll.Results_visibility = PollResultsAfterVote
+	case PollResultsAfterVote, PollResultsAfterClose:
+	default:
+		return nil, fmt.Errorf("results_visibility must be %s or %s", PollResultsAfterVote, PollResultsAfterClose)
+	}
+
+	return flaggedBy, nil
+}
+
+// removeVotesBy deletes every vote a user cast
+// caller must hold the Writer lock and write the db to disk afterwards
+func (db *DB) removeVotesBy(userId int) {
+	for chirpId, votes := range db.dbstruct.PollVotes {
+		delete(votes, userId)
+		if len(votes) == 0 {
+			delete(db.dbstruct.PollVotes, chirpId)
+		}
+	}
+}
+
+// the votes a user cast, oldest poll first
+// caller must hold a Reader or Writer lock
+func (db *DB) votesBy(userId int) []PollVote {
+	votes := []PollVote{}
+	for chirpId, voters := range db.dbstruct.PollVotes {
+		if option, ok := voters[userId]; ok {
+			votes = append(votes, PollVote{Chirp_id: chirpId, Option: option})
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool {
+		return votes[i].Chirp_id < votes[j].Chirp_id
+	})
+	return votes
+}
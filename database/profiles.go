@@ -0,0 +1,32 @@
+package database
+
+import "fmt"
+
+// Profile is what anyone can see about a user
+type Profile struct {
+	User            User
+	Follower_count  int
+	Following_count int
+	// the pinned chirps the viewer can see, most recently pinned first
+	Pinned_chirps []Chirp
+}
+
+// GetProfile returns a user's profile as seen by the viewer
+// users the viewer can't see, because they are deactivated or one of them blocked the other, are reported as missing
+func (db *DB) GetProfile(userId, viewerId int) (Profile, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	user, ok := db.dbstruct.Users[userId]
+	if !ok || db.isHiddenFrom(userId, viewerId) {
+		return Profile{}, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	return Profile{
+		User:            user,
+		Follower_count:  len(db.followersOf[userId]),
+		Following_count: len(db.dbstruct.Follows[userId]),
+		Pinned_chirps:   db.pinnedChirps(userId, viewerId),
+	}, nil
+}
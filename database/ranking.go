@@ -0,0 +1,65 @@
+package database
+
+import "fmt"
+
+// TimelineCandidate is a home timeline entry with what a ranked timeline needs to score it
+type TimelineCandidate struct {
+	TimelineEntry
+	Like_count    int
+	Rechirp_count int
+	Reply_count   int
+	// how many of the author's chirps the viewer liked, replied to or rechirped
+	Author_affinity int
+}
+
+// GetTimelineCandidates returns the newest entries of a user's home timeline, up to limit,
+// with their engagement and how much the user interacts with their authors
+// the same entries GetTimeline would return, for ranking them instead of showing them newest first
+func (db *DB) GetTimelineCandidates(userId, limit int) ([]TimelineCandidate, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok {
+		return nil, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	sources := map[int]bool{userId: true}
+	for followeeId := range db.dbstruct.Follows[userId] {
+		sources[followeeId] = true
+	}
+
+	affinity := db.affinityOf(userId)
+	candidates := []TimelineCandidate{}
+	for _, entry := range db.timelineFrom(sources, userId, limit, 0) {
+		candidates = append(candidates, TimelineCandidate{
+			TimelineEntry:   entry,
+			Like_count:      len(db.dbstruct.Likes[entry.Chirp.Id]),
+			Rechirp_count:   len(db.rechirpsByChirp[entry.Chirp.Id]),
+			Reply_count:     len(db.repliesTo[entry.Chirp.Id]),
+			Author_affinity: affinity[entry.Chirp.Author_id],
+		})
+	}
+	return candidates, nil
+}
+
+// how many times a user liked, replied to or rechirped each author's chirps, by author id
+// caller must hold a Reader or Writer lock
+func (db *DB) affinityOf(userId int) map[int]int {
+	affinity := map[int]int{}
+	for chirpId := range db.likesByUser[userId] {
+		affinity[db.dbstruct.Chirps[chirpId].Author_id]++
+	}
+	for _, rechirp := range db.dbstruct.Rechirps {
+		if rechirp.User_id == userId {
+			affinity[db.dbstruct.Chirps[rechirp.Chirp_id].Author_id]++
+		}
+	}
+	for _, chirp := range db.dbstruct.Chirps {
+		if chirp.Author_id == userId && chirp.In_reply_to != 0 {
+			affinity[db.dbstruct.Chirps[chirp.In_reply_to].Author_id]++
+		}
+	}
+	delete(affinity, userId)
+	return affinity
+}
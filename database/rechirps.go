@@ -0,0 +1,176 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Rechirp is a user sharing someone's chirp with their followers
+// it only points at the original, the chirp's content is never copied
+type Rechirp struct {
+	Id         int       `json:"id"`
+	User_id    int       `json:"user_id"`
+	Chirp_id   int       `json:"chirp_id"`
+	Created_at time.Time `json:"created_at"`
+}
+
+// TimelineEntry is a chirp on a home timeline
+// Rechirped_by is set when the chirp is there because someone the viewer follows rechirped it
+type TimelineEntry struct {
+	Chirp        Chirp
+	Rechirped_by int
+	At           time.Time
+}
+
+// RechirpChirp shares a chirp on behalf of a user
+func (db *DB) RechirpChirp(userId, chirpId int) (Rechirp, error) {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, userId) {
+		return Rechirp{}, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+	if _, ok := db.rechirpsByChirp[chirpId][userId]; ok {
+		return Rechirp{}, errors.New("you already rechirped that chirp")
+	}
+	// rechirping would show it to the rechirper's followers
+	if chirp.Visibility == VisibilityFollowers || db.dbstruct.Users[chirp.Author_id].Is_protected {
+		return Rechirp{}, errors.New("followers-only chirps and chirps by protected users can't be rechirped")
+	}
+
+	rechirp := Rechirp{
+		Id:         db.nextId("rechirps"),
+		User_id:    userId,
+		Chirp_id:   chirpId,
+		Created_at: time.Now(),
+	}
+	db.addRechirp(rechirp)
+	db.writeDB()
+
+	return rechirp, nil
+}
+
+// UndoRechirp removes a user's rechirp of a chirp
+func (db *DB) UndoRechirp(userId, chirpId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	rechirpId, ok := db.rechirpsByChirp[chirpId][userId]
+	if !ok {
+		return errors.New("you haven't rechirped that chirp")
+	}
+
+	db.removeRechirp(rechirpId)
+	db.writeDB()
+
+	return nil
+}
+
+// GetRechirpInfo returns how many times a chirp was rechirped and whether the viewer is one of them
+// viewerId 0 means an anonymous viewer
+func (db *DB) GetRechirpInfo(chirpId, viewerId int) (int, bool) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	_, rechirped := db.rechirpsByChirp[chirpId][viewerId]
+	return len(db.rechirpsByChirp[chirpId]), rechirped
+}
+
+// GetTimeline returns a user's home timeline, newest first:
+// chirps by the user and the people they follow, plus the chirps those people rechirped
+// a chirp only shows up once, at the most recent time it was posted or rechirped
+// chirps by and rechirps from users the viewer blocked or muted are left out
+func (db *DB) GetTimeline(userId, limit, offset int) ([]TimelineEntry, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok {
+		return nil, fmt.Errorf("user with ID %d not found", userId)
+	}
+
+	// whose chirps and rechirps show up
+	sources := map[int]bool{userId: true}
+	for followeeId := range db.dbstruct.Follows[userId] {
+		sources[followeeId] = true
+	}
+
+	return db.timelineFrom(sources, userId, limit, offset), nil
+}
+
+// timelineFrom returns a page of the chirps and rechirps by the given users that the user can see, newest first
+// used by GetTimeline and GetListTimeline
+// caller must hold a Reader or Writer lock
+func (db *DB) timelineFrom(sources map[int]bool, userId, limit, offset int) []TimelineEntry {
+	entries := map[int]TimelineEntry{}
+	for _, chirp := range db.dbstruct.Chirps {
+		if sources[chirp.Author_id] && !db.isMutedOrHidden(chirp.Author_id, userId) && !db.isChirpHiddenFrom(chirp, userId) {
+			entries[chirp.Id] = TimelineEntry{Chirp: chirp, At: chirp.Created_at}
+		}
+	}
+	for _, rechirp := range db.dbstruct.Rechirps {
+		if !sources[rechirp.User_id] || db.isMutedOrHidden(rechirp.User_id, userId) {
+			continue
+		}
+		chirp := db.dbstruct.Chirps[rechirp.Chirp_id]
+		if db.isMutedOrHidden(chirp.Author_id, userId) || db.isChirpHiddenFrom(chirp, userId) {
+			continue
+		}
+		if entry, ok := entries[chirp.Id]; ok && !entry.At.Before(rechirp.Created_at) {
+			continue
+		}
+		entries[chirp.Id] = TimelineEntry{Chirp: chirp, Rechirped_by: rechirp.User_id, At: rechirp.Created_at}
+	}
+
+	timeline := []TimelineEntry{}
+	for _, entry := range entries {
+		timeline = append(timeline, entry)
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		if !timeline[i].At.Equal(timeline[j].At) {
+			return timeline[i].At.After(timeline[j].At)
+		}
+		return timeline[i].Chirp.Id > timeline[j].Chirp.Id
+	})
+
+	return paginate(timeline, limit, offset)
+}
+
+// addRechirp stores a rechirp in both the stored rechirps and the per chirp index
+// caller must hold the Writer lock
+func (db *DB) addRechirp(rechirp Rechirp) {
+	db.dbstruct.Rechirps[rechirp.Id] = rechirp
+	if db.rechirpsByChirp[rechirp.Chirp_id] == nil {
+		db.rechirpsByChirp[rechirp.Chirp_id] = make(map[int]int)
+	}
+	db.rechirpsByChirp[rechirp.Chirp_id][rechirp.User_id] = rechirp.Id
+}
+
+// removeRechirp deletes a rechirp from both the stored rechirps and the per chirp index
+// caller must hold the Writer lock
+func (db *DB) removeRechirp(rechirpId int) {
+	rechirp := db.dbstruct.Rechirps[rechirpId]
+	delete(db.dbstruct.Rechirps, rechirpId)
+	delete(db.rechirpsByChirp[rechirp.Chirp_id], rechirp.User_id)
+	if len(db.rechirpsByChirp[rechirp.Chirp_id]) == 0 {
+		delete(db.rechirpsByChirp, rechirp.Chirp_id)
+	}
+}
+
+// indexRechirps builds the per chirp rechirp index from the stored rechirps
+// used by NewDB after loading the db
+func (db *DB) indexRechirps() {
+	db.rechirpsByChirp = make(map[int]map[int]int)
+	for _, rechirp := range db.dbstruct.Rechirps {
+		if db.rechirpsByChirp[rechirp.Chirp_id] == nil {
+			db.rechirpsByChirp[rechirp.Chirp_id] = make(map[int]int)
+		}
+		db.rechirpsByChirp[rechirp.Chirp_id][rechirp.User_id] = rechirp.Id
+	}
+}
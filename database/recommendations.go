@@ -0,0 +1,202 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// reasons a user is recommended, see Recommendation
+const (
+	RecommendationFollowedBy = "followed_by"
+	RecommendationFollowsYou = "follows_you"
+	RecommendationInteracted = "interacted"
+	RecommendationPopular    = "popular"
+)
+
+// how much each signal adds to a recommendation's score,
+// popularity adds log2(1 + followers) so a few very popular accounts don't crowd out everyone else
+const (
+	followedByWeight = 3.0
+	followsYouWeight = 4.0
+	likedWeight      = 1.0
+	repliedWeight    = 2.0
+)
+
+// how many of the users followed by the viewer who follow the recommended user are named
+const maxFollowedByShown = 3
+
+// Recommendation is a user the viewer might want to follow, with why
+type Recommendation struct {
+	User  User    `json:"user"`
+	Score float64 `json:"score"`
+	// RecommendationFollowedBy, RecommendationFollowsYou, RecommendationInteracted and RecommendationPopular,
+	// popular is only given when nothing else explains the recommendation
+	Reasons []string `json:"reasons"`
+	// some of the users the viewer follows who follow them, most recent follow first
+	Followed_by       []User `json:"followed_by"`
+	Followed_by_count int    `json:"followed_by_count"`
+	Follows_you       bool   `json:"follows_you"`
+	// how many of their chirps the viewer liked or replied to
+	Liked_count    int `json:"liked_count"`
+	Replied_count  int `json:"replied_count"`
+	Follower_count int `json:"follower_count"`
+}
+
+// Dismissal is a user someone doesn't want recommended to them anymore
+type Dismissal struct {
+	User_id      int       `json:"user_id"`
+	Dismissed_id int       `json:"dismissed_id"`
+	Dismissed_at time.Time `json:"dismissed_at"`
+}
+
+// GetRecommendedUsers suggests users for the viewer to follow, best first
+// users are suggested when people the viewer follows follow them, when they follow the viewer,
+// when the viewer liked or replied to their chirps, and by how many followers they have
+// users the viewer follows or asked to follow, dismissed, blocked, was blocked by or muted,
+// and suspended users are never suggested
+func (db *DB) GetRecommendedUsers(viewerId, limit, offset int) ([]Recommendation, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	if _, ok := db.dbstruct.Users[viewerId]; !ok {
+		return nil, errors.New("user not found")
+	}
+
+	candidates := map[int]*Recommendation{}
+	candidate := func(userId int) *Recommendation {
+		if recommendation, ok := candidates[userId]; ok {
+			return recommendation
+		}
+		if !db.canRecommend(userId, viewerId) {
+			return nil
+		}
+		candidates[userId] = &Recommendation{User: db.dbstruct.Users[userId], Followed_by: []User{}}
+		return candidates[userId]
+	}
+
+	// friends of friends, remembering who connects them
+	followedBy := map[int]map[int]time.Time{}
+	for followeeId := range db.dbstruct.Follows[viewerId] {
+		if db.isMutedOrHidden(followeeId, viewerId) {
+			continue
+		}
+		for userId, since := range db.dbstruct.Follows[followeeId] {
+			if recommendation := candidate(userId); recommendation != nil {
+				recommendation.Followed_by_count++
+				if followedBy[userId] == nil {
+					followedBy[userId] = map[int]time.Time{}
+				}
+				followedBy[userId][followeeId] = since
+			}
+		}
+	}
+
+	for followerId := range db.followersOf[viewerId] {
+		if recommendation := candidate(followerId); recommendation != nil {
+			recommendation.Follows_you = true
+		}
+	}
+
+	for chirpId := range db.likesByUser[viewerId] {
+		if recommendation := candidate(db.dbstruct.Chirps[chirpId].Author_id); recommendation != nil {
+			recommendation.Liked_count++
+		}
+	}
+	for _, chirp := range db.dbstruct.Chirps {
+		if chirp.Author_id != viewerId || chirp.In_reply_to == 0 {
+			continue
+		}
+		if recommendation := candidate(db.dbstruct.Chirps[chirp.In_reply_to].Author_id); recommendation != nil {
+			recommendation.Replied_count++
+		}
+	}
+
+	// popular users, for new users who don't follow anyone yet
+	for userId, followers := range db.followersOf {
+		if len(followers) == 0 {
+			continue
+		}
+		candidate(userId)
+	}
+
+	recommendations := []Recommendation{}
+	for userId, recommendation := range candidates {
+		recommendation.Follower_count = len(db.followersOf[userId])
+		score := followedByWeight*float64(recommendation.Followed_by_count) +
+			likedWeight*float64(recommendation.Liked_count) +
+			repliedWeight*float64(recommendation.Replied_count) +
+			math.Log2(1+float64(recommendation.Follower_count))
+		if recommendation.Follows_you {
+			score += followsYouWeight
+		}
+		recommendation.Score = math.Round(score*100) / 100
+
+		recommendation.Reasons = []string{}
+		if recommendation.Followed_by_count > 0 {
+			recommendation.Reasons = append(recommendation.Reasons, RecommendationFollowedBy)
+			recommendation.Followed_by = db.usersFromFollowMap(followedBy[userId], viewerId, maxFollowedByShown, 0)
+		}
+		if recommendation.Follows_you {
+			recommendation.Reasons = append(recommendation.Reasons, RecommendationFollowsYou)
+		}
+		if recommendation.Liked_count > 0 || recommendation.Replied_count > 0 {
+			recommendation.Reasons = append(recommendation.Reasons, RecommendationInteracted)
+		}
+		if len(recommendation.Reasons) == 0 {
+			recommendation.Reasons = append(recommendation.Reasons, RecommendationPopular)
+		}
+		recommendations = append(recommendations, *recommendation)
+	}
+
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].User.Id < recommendations[j].User.Id
+	})
+
+	return paginate(recommendations, limit, offset), nil
+}
+
+// DismissRecommendation stops a user from being recommended to the viewer, dismissing someone twice does nothing
+func (db *DB) DismissRecommendation(viewerId, userId int) error {
+	// Writer lock
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if _, ok := db.dbstruct.Users[userId]; !ok || db.isHiddenFrom(userId, viewerId) {
+		return fmt.Errorf("user with ID %d not found", userId)
+	}
+	if _, ok := db.dbstruct.DismissedRecommendations[viewerId][userId]; ok {
+		return nil
+	}
+
+	if db.dbstruct.DismissedRecommendations[viewerId] == nil {
+		db.dbstruct.DismissedRecommendations[viewerId] = make(map[int]time.Time)
+	}
+	db.dbstruct.DismissedRecommendations[viewerId][userId] = time.Now()
+	db.writeDB()
+
+	return nil
+}
+
+// checks if a user may be recommended to the viewer at all
+// caller must hold a Reader or Writer lock
+func (db *DB) canRecommend(userId, viewerId int) bool {
+	user, ok := db.dbstruct.Users[userId]
+	if !ok || userId == viewerId || user.IsSuspended() || db.isMutedOrHidden(userId, viewerId) {
+		return false
+	}
+	if _, ok := db.dbstruct.Follows[viewerId][userId]; ok {
+		return false
+	}
+	if _, ok := db.dbstruct.FollowRequests[viewerId][userId]; ok {
+		return false
+	}
+	_, dismissed := db.dbstruct.DismissedRecommendations[viewerId][userId]
+	return !dismissed
+}
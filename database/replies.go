@@ -0,0 +1,95 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GetReplies returns the chirps replying directly to a chirp that the viewer can see, oldest first
+func (db *DB) GetReplies(chirpId, viewerId, limit, offset int) ([]Chirp, error) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	chirp, ok := db.dbstruct.Chirps[chirpId]
+	if !ok || db.isChirpHiddenFrom(chirp, viewerId) {
+		return nil, fmt.Errorf("chirp with ID %d not found", chirpId)
+	}
+
+	replies := []Chirp{}
+	for replyId := range db.repliesTo[chirpId] {
+		reply := db.dbstruct.Chirps[replyId]
+		if !db.isChirpHiddenFrom(reply, viewerId) {
+			replies = append(replies, reply)
+		}
+	}
+	sort.Slice(replies, func(i, j int) bool {
+		return replies[i].Id < replies[j].Id
+	})
+
+	return paginate(replies, limit, offset), nil
+}
+
+// GetReplyCount returns how many chirps reply directly to a chirp
+func (db *DB) GetReplyCount(chirpId int) int {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return len(db.repliesTo[chirpId])
+}
+
+// IsInThread checks if a chirp is the root of a thread or replies somewhere below it
+// works for chirps that were already deleted too, as long as their parents weren't
+func (db *DB) IsInThread(chirp Chirp, rootId int) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	// follow the chain of replies up, for as long as it could possibly be
+	current := chirp
+	for i := 0; i <= len(db.dbstruct.Chirps); i++ {
+		if current.Id == rootId {
+			return true
+		}
+		if current.In_reply_to == 0 {
+			return false
+		}
+		parent, ok := db.dbstruct.Chirps[current.In_reply_to]
+		if !ok {
+			return current.In_reply_to == rootId
+		}
+		current = parent
+	}
+	return false
+}
+
+// indexReply adds a reply to the replies index, does nothing for chirps that aren't replies
+// caller must hold the Writer lock
+func (db *DB) indexReply(chirp Chirp) {
+	if chirp.In_reply_to == 0 {
+		return
+	}
+	if db.repliesTo[chirp.In_reply_to] == nil {
+		db.repliesTo[chirp.In_reply_to] = make(map[int]bool)
+	}
+	db.repliesTo[chirp.In_reply_to][chirp.Id] = true
+}
+
+// unindexReply removes a reply from the replies index
+// caller must hold the Writer lock
+func (db *DB) unindexReply(chirp Chirp) {
+	delete(db.repliesTo[chirp.In_reply_to], chirp.Id)
+	if len(db.repliesTo[chirp.In_reply_to]) == 0 {
+		delete(db.repliesTo, chirp.In_reply_to)
+	}
+}
+
+// indexReplies builds the replies index from the stored chirps
+// used by NewDB after loading the db
+func (db *DB) indexReplies() {
+	db.repliesTo = make(map[int]map[int]bool)
+	for _, chirp := range db.dbstruct.Chirps {
+		db.indexReply(chirp)
+	}
+}
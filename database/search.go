@@ -0,0 +1,244 @@
+package database
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SearchQuery is a parsed search query
+// every part of it has to match for a chirp to be a result
+type SearchQuery struct {
+	Terms    []string   // words, anywhere in the chirp
+	Phrases  [][]string // words that have to appear next to each other, in order
+	Hashtags []string   // #tag operators
+	From     []string   // from:handle or from:id operators, any of them can match
+}
+
+// ParseSearchQuery splits a query like `"hello world" #go from:alice cats` into its parts
+func ParseSearchQuery(q string) SearchQuery {
+	query := SearchQuery{}
+
+	// pull out the quoted phrases first
+	for {
+		start := strings.Index(q, `"`)
+		if start == -1 {
+			break
+		}
+		end := strings.Index(q[start+1:], `"`)
+		if end == -1 {
+			// an unclosed quote is just a quote
+			q = q[:start] + " " + q[start+1:]
+			break
+		}
+		end += start + 1
+		if phrase := tokenTerms(q[start+1 : end]); len(phrase) == 1 {
+			query.Terms = append(query.Terms, phrase...)
+		} else if len(phrase) > 1 {
+			query.Phrases = append(query.Phrases, phrase)
+		}
+		q = q[:start] + " " + q[end+1:]
+	}
+
+	for _, word := range strings.Fields(q) {
+		switch {
+		case strings.HasPrefix(word, "#") && len(word) > 1:
+			query.Hashtags = append(query.Hashtags, strings.ToLower(word[1:]))
+		case strings.HasPrefix(strings.ToLower(word), "from:") && len(word) > 5:
+			query.From = append(query.From, strings.TrimPrefix(word[5:], "@"))
+		default:
+			query.Terms = append(query.Terms, tokenTerms(word)...)
+		}
+	}
+
+	return query
+}
+
+// IsEmpty reports whether the query has nothing to search for
+func (query SearchQuery) IsEmpty() bool {
+	return len(query.Terms) == 0 && len(query.Phrases) == 0 && len(query.Hashtags) == 0 && len(query.From) == 0
+}
+
+// SearchChirps finds the chirps matching a query
+// orderScheme is either "relevance" (best match first) or "recency" (newest first)
+// chirps the viewer can't see are left out
+// returns the page of results and the total number of results
+func (db *DB) SearchChirps(query SearchQuery, orderScheme string, viewerId, limit, offset int) ([]Chirp, int) {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	// every word of the query, phrases included, has to be in the chirp
+	words := append([]string{}, query.Terms...)
+	for _, phrase := range query.Phrases {
+		words = append(words, phrase...)
+	}
+
+	// start from the smallest set of candidates we can get from an index
+	var candidates map[int]bool
+	narrow := func(ids map[int]bool) {
+		if candidates == nil {
+			candidates = make(map[int]bool, len(ids))
+			for id := range ids {
+				candidates[id] = true
+			}
+			return
+		}
+		for id := range candidates {
+			if !ids[id] {
+				delete(candidates, id)
+			}
+		}
+	}
+	for _, word := range words {
+		postings := map[int]bool{}
+		for id := range db.searchIndex[word] {
+			postings[id] = true
+		}
+		narrow(postings)
+	}
+	for _, tag := range query.Hashtags {
+		narrow(db.chirpsByHashtag[tag])
+	}
+	if len(query.From) > 0 {
+		authors := db.resolveAuthors(query.From)
+		fromAuthors := map[int]bool{}
+		for id, chirp := range db.dbstruct.Chirps {
+			if authors[chirp.Author_id] {
+				fromAuthors[id] = true
+			}
+		}
+		narrow(fromAuthors)
+	}
+
+	type result struct {
+		chirp Chirp
+		score float64
+	}
+	results := []result{}
+	for id := range candidates {
+		chirp := db.dbstruct.Chirps[id]
+		if !chirp.IsListed() || db.isChirpHiddenFrom(chirp, viewerId) || !db.containsPhrases(id, query.Phrases) {
+			continue
+		}
+		results = append(results, result{chirp: chirp, score: db.relevance(id, words)})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if orderScheme == "relevance" && results[i].score != results[j].score {
+			return results[i].score > results[j].score
+		}
+		return results[i].chirp.Id > results[j].chirp.Id
+	})
+
+	chirps := []Chirp{}
+	for _, result := range paginate(results, limit, offset) {
+		chirps = append(chirps, result.chirp)
+	}
+
+	return chirps, len(results)
+}
+
+// turns from: operators into user ids, either handles or ids work
+// caller must hold a Reader or Writer lock
+func (db *DB) resolveAuthors(from []string) map[int]bool {
+	authors := map[int]bool{}
+	for _, author := range from {
+		if userId, ok := db.usersByHandle[strings.ToLower(author)]; ok {
+			authors[userId] = true
+		} else if userId, err := strconv.Atoi(author); err == nil {
+			authors[userId] = true
+		}
+	}
+	return authors
+}
+
+// checks that every phrase appears in the chirp, word after word
+// caller must hold a Reader or Writer lock
+func (db *DB) containsPhrases(chirpId int, phrases [][]string) bool {
+	for _, phrase := range phrases {
+		found := false
+		for _, start := range db.searchIndex[phrase[0]][chirpId] {
+			matches := true
+			for i, word := range phrase[1:] {
+				if !containsInt(db.searchIndex[word][chirpId], start+i+1) {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// tf-idf score of a chirp for the query words
+// rare words count for more than common ones, short chirps a little more than long ones
+// caller must hold a Reader or Writer lock
+func (db *DB) relevance(chirpId int, words []string) float64 {
+	total := float64(len(db.dbstruct.Chirps))
+	score := 0.0
+	for _, word := range words {
+		postings := db.searchIndex[word]
+		tf := float64(len(postings[chirpId]))
+		idf := math.Log(1 + total/float64(len(postings)))
+		score += tf * idf
+	}
+	length := float64(len(tokenTerms(db.dbstruct.Chirps[chirpId].Body)))
+	return score / math.Sqrt(1+length)
+}
+
+// indexChirpText adds a chirp's words to the search index
+// caller must hold the Writer lock
+func (db *DB) indexChirpText(chirp Chirp) {
+	for position, term := range tokenTerms(chirp.Body) {
+		if db.searchIndex[term] == nil {
+			db.searchIndex[term] = make(map[int][]int)
+		}
+		db.searchIndex[term][chirp.Id] = append(db.searchIndex[term][chirp.Id], position)
+	}
+}
+
+// unindexChirpText removes a chirp's words from the search index
+// caller must hold the Writer lock
+func (db *DB) unindexChirpText(chirp Chirp) {
+	for _, term := range tokenTerms(chirp.Body) {
+		delete(db.searchIndex[term], chirp.Id)
+		if len(db.searchIndex[term]) == 0 {
+			delete(db.searchIndex, term)
+		}
+	}
+}
+
+// indexSearch builds the search index from the stored chirps
+// used by NewDB after loading the db
+func (db *DB) indexSearch() {
+	db.searchIndex = make(map[string]map[int][]int)
+	for _, chirp := range db.dbstruct.Chirps {
+		db.indexChirpText(chirp)
+	}
+}
+
+// splits text into lowercase words, anything that isn't a letter or digit separates words
+func tokenTerms(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c) && !unicode.Is(unicode.Mn, c)
+	})
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package database
+
+import "time"
+
+// CanTrend checks if a chirp may count towards trends
+// only public chirps by public accounts that are in good standing do
+func (db *DB) CanTrend(chirp Chirp) bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return db.canTrend(chirp)
+}
+
+// caller must hold a Reader or Writer lock
+func (db *DB) canTrend(chirp Chirp) bool {
+	author, ok := db.dbstruct.Users[chirp.Author_id]
+	return ok && chirp.IsPublic() && !chirp.IsHidden() &&
+		!author.Is_protected && !author.IsDeactivated() && !author.IsSuspended()
+}
+
+// GetTrendableChirps returns the chirps created since the given time that may count towards trends
+// used to catch trends up after a restart
+func (db *DB) GetTrendableChirps(since time.Time) []Chirp {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	chirps := []Chirp{}
+	for _, chirp := range db.dbstruct.Chirps {
+		if !chirp.Created_at.Before(since) && db.canTrend(chirp) {
+			chirps = append(chirps, chirp)
+		}
+	}
+	return chirps
+}
+
+// GetSpamFlaggedChirpIds returns the ids of the chirps with an open report for spam,
+// or flagged by the content filter
+func (db *DB) GetSpamFlaggedChirpIds() map[int]bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	flagged := map[int]bool{}
+	for _, report := range db.dbstruct.Reports {
+		if report.Status == ReportOpen && report.Target_type == ReportTargetChirp &&
+			(report.Reason == "spam" || report.Reason == ReportReasonContentFilter) {
+			flagged[report.Chirp_id] = true
+		}
+	}
+	return flagged
+}
+
+// GetUsersHiddenFrom returns the ids of the users whose content is kept from the viewer:
+// the ones they blocked or were blocked by, and the ones they muted
+func (db *DB) GetUsersHiddenFrom(viewerId int) map[int]bool {
+	// lock for Readers
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	hidden := map[int]bool{}
+	for userId := range db.dbstruct.Blocks[viewerId] {
+		hidden[userId] = true
+	}
+	for userId := range db.dbstruct.Mutes[viewerId] {
+		hidden[userId] = true
+	}
+	for blockerId, blocked := range db.dbstruct.Blocks {
+		if _, ok := blocked[viewerId]; ok {
+			hidden[blockerId] = true
+		}
+	}
+	return hidden
+}
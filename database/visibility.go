@@ -0,0 +1,47 @@
+package database
+
+import "errors"
+
+// who can see a chirp
+const (
+	// everyone, everywhere
+	VisibilityPublic = "public"
+	// everyone with the link, and the author's followers on their timelines,
+	// but it is left out of the list of all chirps, search, hashtags and the public stream
+	VisibilityUnlisted = "unlisted"
+	// only the author and their followers
+	VisibilityFollowers = "followers"
+)
+
+// IsPublic reports whether anyone can find the chirp
+// chirps from before visibility existed are public
+func (chirp Chirp) IsPublic() bool {
+	return chirp.Visibility == "" || chirp.Visibility == VisibilityPublic
+}
+
+// IsListed reports whether the chirp shows up in listings that aren't about its author,
+// like all chirps, search and hashtags
+func (chirp Chirp) IsListed() bool {
+	return chirp.Visibility != VisibilityUnlisted
+}
+
+// checks the visibility of a new chirp, an empty one is public
+func checkVisibility(visibility string) (string, error) {
+	switch visibility {
+	case "":
+		return VisibilityPublic, nil
+	case VisibilityPublic, VisibilityUnlisted, VisibilityFollowers:
+		return visibility, nil
+	}
+	return "", errors.New("visibility must be public, unlisted or followers")
+}
+
+// isVisibilityHiddenFrom checks if a followers-only chirp is hidden from the viewer
+// caller must hold a Reader or Writer lock
+func (db *DB) isVisibilityHiddenFrom(chirp Chirp, viewerId int) bool {
+	if chirp.Visibility != VisibilityFollowers || chirp.Author_id == viewerId {
+		return false
+	}
+	_, following := db.dbstruct.Follows[viewerId][chirp.Author_id]
+	return viewerId == 0 || !following
+}
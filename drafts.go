@@ -0,0 +1,229 @@
+package main
+
+import (
+	"chirpy/database"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// the scheduler checks for due chirps at least this often, even when nothing seems due
+const schedulerMaxSleep = time.Minute
+
+// scheduler publishes scheduled chirps when their time comes
+// everything it needs is in the db, so it picks up where it left off after a restart
+type scheduler struct {
+	wake chan struct{}
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{wake: make(chan struct{}, 1)}
+}
+
+// Wake makes the scheduler look at the schedule again, after it changed
+func (s *scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// publishes due chirps, then sleeps until the next one is due or the schedule changes
+// meant to be run as a goroutine
+func (apiCfg apiConfig) runScheduler() {
+	for {
+		for _, draftId := range apiCfg.db.GetDueDrafts(time.Now()) {
+			chirp, err := apiCfg.db.PublishDraft(draftId, 0)
+			if err != nil {
+				log.Printf("scheduled chirp %d could not be published: %v", draftId, err)
+				continue
+			}
+			apiCfg.announceChirp(chirp)
+		}
+
+		wait := schedulerMaxSleep
+		if next, ok := apiCfg.db.NextScheduledAt(); ok && time.Until(next) < wait {
+			wait = time.Until(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-apiCfg.scheduler.wake:
+			timer.Stop()
+		}
+	}
+}
+
+// POST /api/drafts
+// save a chirp for later, authenticated endpoint
+// with `scheduled_at` it is published at that time, without it it waits until it is published by hand
+func (apiCfg apiConfig) createDraftHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/drafts")
+	apiCfg.saveDraft(w, r, 0)
+}
+
+// PUT /api/drafts/{id}
+// replace a draft or scheduled chirp, authenticated endpoint
+// leaving `scheduled_at` out turns a scheduled chirp back into a draft
+func (apiCfg apiConfig) updateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: PUT /api/drafts/{id}")
+	draftId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	apiCfg.saveDraft(w, r, draftId)
+}
+
+// used by createDraftHandler and updateDraftHandler
+// draftId 0 creates a new draft
+func (apiCfg apiConfig) saveDraft(w http.ResponseWriter, r *http.Request, draftId int) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	params := database.Draft{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("could not decode your draft JSON"))
+		return
+	}
+	params.Id = draftId
+	params.Author_id = userId
+
+	if draftId != 0 {
+		if _, err := apiCfg.db.GetDraft(draftId, userId); err != nil {
+			respondWithError(w, http.StatusNotFound, err)
+			return
+		}
+	}
+	draft, err := apiCfg.db.SaveDraft(params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	apiCfg.scheduler.Wake()
+
+	status := http.StatusOK
+	if draftId == 0 {
+		status = http.StatusCreated
+	}
+	respondWithJSON(w, status, draft)
+}
+
+// GET /api/drafts
+// the authenticated user's drafts and scheduled chirps, scheduled ones first, soonest first
+// optional query parameter `status` is draft, scheduled or failed
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/drafts")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", database.DraftSaved, database.DraftScheduled, database.DraftFailed:
+	default:
+		respondWithError(w, http.StatusBadRequest, errors.New("status must be draft, scheduled or failed"))
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	respondWithJSON(w, http.StatusOK, apiCfg.db.GetDrafts(userId, status, limit, offset))
+}
+
+// GET /api/drafts/{id}
+// a single draft or scheduled chirp of the authenticated user
+func (apiCfg apiConfig) readDraftHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/drafts/{id}")
+	userId, draftId, ok := apiCfg.getDraftParams(w, r)
+	if !ok {
+		return
+	}
+
+	draft, err := apiCfg.db.GetDraft(draftId, userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, draft)
+}
+
+// DELETE /api/drafts/{id}
+// throw away a draft, or cancel a scheduled chirp
+func (apiCfg apiConfig) deleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/drafts/{id}")
+	userId, draftId, ok := apiCfg.getDraftParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := apiCfg.db.DeleteDraft(draftId, userId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	apiCfg.scheduler.Wake()
+
+	respondWithJSON(w, http.StatusOK, nil)
+}
+
+// POST /api/drafts/{id}/publish
+// publish a draft or scheduled chirp right away, it goes through the same checks as a new chirp
+func (apiCfg apiConfig) publishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/drafts/{id}/publish")
+	userId, draftId, ok := apiCfg.getDraftParams(w, r)
+	if !ok {
+		return
+	}
+
+	if _, err := apiCfg.db.GetDraft(draftId, userId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	chirp, err := apiCfg.db.PublishDraft(draftId, userId)
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	apiCfg.announceChirp(chirp)
+	apiCfg.scheduler.Wake()
+
+	respondWithJSON(w, http.StatusCreated, apiCfg.newChirpResponse(chirp, userId))
+}
+
+// used by the draft handlers that work on a single draft
+// returns the authenticated user and the draft id from the url,
+// responds with an error and returns false if either is missing
+func (apiCfg apiConfig) getDraftParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return 0, 0, false
+	}
+
+	draftId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Errorf("draft with ID %s not found", chi.URLParam(r, "id")))
+		return 0, 0, false
+	}
+
+	return userId, draftId, true
+}
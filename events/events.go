@@ -0,0 +1,129 @@
+// Package events is the in-process event bus
+// handlers publish what happened (a chirp was created, a user was followed, ...)
+// and the rest of the server (notifications, streams, ...) subscribes to it
+package events
+
+import (
+	"chirpy/database"
+	"log"
+	"sync"
+	"time"
+)
+
+// types of Event
+const (
+	ChirpCreated   = "chirp.created"
+	ChirpEdited    = "chirp.edited"
+	ChirpDeleted   = "chirp.deleted"
+	ChirpLiked     = "chirp.liked"
+	ChirpRechirped = "chirp.rechirped"
+	PollVoted      = "poll.voted"
+	UserFollowed   = "user.followed"
+	// a protected user was asked to approve a follow
+	FollowRequested = "user.follow_requested"
+	UserSuspended   = "user.suspended"
+
+	NotificationCreated = "notification.created"
+)
+
+// Event is something that happened on Chirpy
+type Event struct {
+	Type string
+	At   time.Time
+	// the user who did it
+	Actor_id int
+	// the chirp it happened to, for chirp events
+	Chirp database.Chirp
+	// the user it happened to, for user events
+	User_id int
+	// the notification, for notification events
+	Notification database.Notification
+}
+
+// how many events a subscriber can fall behind before new events are dropped for it
+const subscriberBuffer = 1024
+
+// Bus delivers every published event to every subscriber, in order
+// Publish never waits for a subscriber, so subscribers can publish events of their own
+type Bus struct {
+	mux         *sync.RWMutex
+	subscribers map[int]*subscriber
+	nextId      int
+}
+
+// the events channel is never closed, so Publish can't send on a closed channel
+// when it races with the end of a subscription, done is closed instead
+type subscriber struct {
+	events chan Event
+	done   chan struct{}
+}
+
+// NewBus creates an event bus with no subscribers
+func NewBus() *Bus {
+	return &Bus{
+		mux:         &sync.RWMutex{},
+		subscribers: make(map[int]*subscriber),
+	}
+}
+
+// Subscribe calls handler with every event published from now on
+// each subscriber gets its own goroutine, so a slow handler only delays itself,
+// and misses events once it falls subscriberBuffer events behind
+// returns a function that ends the subscription
+func (bus *Bus) Subscribe(handler func(Event)) func() {
+	bus.mux.Lock()
+	defer bus.mux.Unlock()
+
+	id := bus.nextId
+	bus.nextId++
+	sub := &subscriber{
+		events: make(chan Event, subscriberBuffer),
+		done:   make(chan struct{}),
+	}
+	bus.subscribers[id] = sub
+
+	go func() {
+		for {
+			select {
+			case event := <-sub.events:
+				handler(event)
+			case <-sub.done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		bus.mux.Lock()
+		defer bus.mux.Unlock()
+		if _, ok := bus.subscribers[id]; ok {
+			delete(bus.subscribers, id)
+			close(sub.done)
+		}
+	}
+}
+
+// Publish sends an event to every subscriber without waiting for any of them
+// subscribers that are too far behind miss the event
+// the time of the event is filled in if it isn't set
+func (bus *Bus) Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	bus.mux.RLock()
+	subscribers := make([]*subscriber, 0, len(bus.subscribers))
+	for _, sub := range bus.subscribers {
+		subscribers = append(subscribers, sub)
+	}
+	bus.mux.RUnlock()
+
+	for _, sub := range subscribers {
+		select {
+		case sub.events <- event:
+		case <-sub.done:
+		default:
+			log.Printf("event subscriber is %d events behind, dropping a %s event", subscriberBuffer, event.Type)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// POST /api/users/{id}/follow
+// follow a user as the authenticated user
+func (apiCfg apiConfig) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/{id}/follow")
+	apiCfg.setFollowing(w, r, true)
+}
+
+// DELETE /api/users/{id}/follow
+// unfollow a user as the authenticated user
+func (apiCfg apiConfig) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/users/{id}/follow")
+	apiCfg.setFollowing(w, r, false)
+}
+
+// used by followUserHandler and unfollowUserHandler
+// responds with the followed user's id, whether the authenticated user now follows them,
+// and whether they are waiting for a protected user to approve it
+func (apiCfg apiConfig) setFollowing(w http.ResponseWriter, r *http.Request, following bool) {
+	followerId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	followeeId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	state := ""
+	if following {
+		var isNew bool
+		state, isNew, err = apiCfg.db.FollowUser(followerId, followeeId)
+		if err == nil && isNew {
+			eventType := events.UserFollowed
+			if state == database.FollowStateRequested {
+				eventType = events.FollowRequested
+			}
+			apiCfg.bus.Publish(events.Event{
+				Type:     eventType,
+				Actor_id: followerId,
+				User_id:  followeeId,
+			})
+		}
+	} else {
+		err = apiCfg.db.UnfollowUser(followerId, followeeId)
+	}
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	type retVal struct {
+		User_id   int  `json:"user_id"`
+		Following bool `json:"following"`
+		Requested bool `json:"requested"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{
+		User_id:   followeeId,
+		Following: state == database.FollowStateFollowing,
+		Requested: state == database.FollowStateRequested,
+	})
+}
+
+// GET /api/users/me/follow_requests
+// list the users asking to follow the authenticated user, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/follow_requests")
+	apiCfg.respondWithFollowRequests(w, r, apiCfg.db.GetFollowRequests, func(request database.FollowRequest) int {
+		return request.Requester_id
+	})
+}
+
+// GET /api/users/me/follow_requests/sent
+// list the protected users the authenticated user asked to follow that haven't answered yet, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readSentFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/me/follow_requests/sent")
+	apiCfg.respondWithFollowRequests(w, r, apiCfg.db.GetSentFollowRequests, func(request database.FollowRequest) int {
+		return request.Target_id
+	})
+}
+
+// used by readFollowRequestsHandler and readSentFollowRequestsHandler
+// responds with the page of requests from the db query, along with the other user of each request
+func (apiCfg apiConfig) respondWithFollowRequests(w http.ResponseWriter, r *http.Request, query func(userId, limit, offset int) []database.FollowRequest, otherUser func(database.FollowRequest) int) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	type followRequestResponse struct {
+		database.FollowRequest
+		User publicUser `json:"user"`
+	}
+
+	limit, offset := getPaginationParams(r)
+	requests := []followRequestResponse{}
+	for _, request := range query(userId, limit, offset) {
+		user, err := apiCfg.db.GetUser(otherUser(request))
+		if err != nil {
+			continue
+		}
+		requests = append(requests, followRequestResponse{FollowRequest: request, User: newPublicUser(user)})
+	}
+
+	respondWithJSON(w, http.StatusOK, requests)
+}
+
+// POST /api/users/me/follow_requests/{id}/approve
+// lets the user with the given id follow the authenticated user
+func (apiCfg apiConfig) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/me/follow_requests/{id}/approve")
+	apiCfg.answerFollowRequest(w, r, true)
+}
+
+// POST /api/users/me/follow_requests/{id}/reject
+// turns down the user with the given id, they aren't told
+func (apiCfg apiConfig) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/me/follow_requests/{id}/reject")
+	apiCfg.answerFollowRequest(w, r, false)
+}
+
+// used by approveFollowRequestHandler and rejectFollowRequestHandler
+// responds with the requester's id and whether they now follow the authenticated user
+func (apiCfg apiConfig) answerFollowRequest(w http.ResponseWriter, r *http.Request, approve bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	requesterId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	if approve {
+		err = apiCfg.db.ApproveFollowRequest(userId, requesterId)
+	} else {
+		err = apiCfg.db.RejectFollowRequest(userId, requesterId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	type retVal struct {
+		User_id   int  `json:"user_id"`
+		Following bool `json:"following"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{User_id: requesterId, Following: approve})
+}
+
+// GET /api/users/{id}/followers
+// list the users following a user, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/{id}/followers")
+	apiCfg.respondWithFollowList(w, r, apiCfg.db.GetFollowers)
+}
+
+// GET /api/users/{id}/following
+// list the users a user follows, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/{id}/following")
+	apiCfg.respondWithFollowList(w, r, apiCfg.db.GetFollowing)
+}
+
+// used by readFollowersHandler and readFollowingHandler
+// looks up the user in the url with the given db query and responds with the page of users
+func (apiCfg apiConfig) respondWithFollowList(w http.ResponseWriter, r *http.Request, query func(userId, viewerId, limit, offset int) ([]database.User, error)) {
+	userId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	found, err := query(userId, apiCfg.getOptionalUserId(r), limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	users := []publicUser{}
+	for _, user := range found {
+		users = append(users, newPublicUser(user))
+	}
+
+	respondWithJSON(w, http.StatusOK, users)
+}
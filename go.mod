@@ -4,9 +4,10 @@ module chirpy
 go 1.20
 
 require (
-	github.com/go-chi/chi v1.5.4 // indirect
-	github.com/go-chi/chi/v5 v5.0.8 // indirect
-	github.com/golang-jwt/jwt/v5 v5.0.0 // indirect
-	github.com/joho/godotenv v1.5.1 // indirect
-	golang.org/x/crypto v0.9.0 // indirect
+	github.com/go-chi/chi v1.5.4
+	github.com/golang-jwt/jwt/v5 v5.0.0
+	github.com/joho/godotenv v1.5.1
+	golang.org/x/crypto v0.9.0
 )
+
+require github.com/go-chi/chi/v5 v5.0.8 // indirect
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi"
+)
+
+// GET /api/hashtags/{tag}/chirps
+// list the chirps tagged with a hashtag, newest first
+// the tag is matched ignoring case, with or without a leading # (url encoded as %23)
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readHashtagChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/hashtags/{tag}/chirps")
+	tag := strings.TrimPrefix(chi.URLParam(r, "tag"), "#")
+
+	limit, offset := getPaginationParams(r)
+	viewerId := apiCfg.getOptionalUserId(r)
+	chirps := apiCfg.db.GetChirpsByHashtag(tag, viewerId, limit, offset)
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newChirpResponses(chirps, viewerId))
+}
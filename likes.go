@@ -0,0 +1,122 @@
+package main
+
+import (
+	"chirpy/events"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// POST /api/chirps/{id}/like
+// like a chirp as the authenticated user, liking a chirp twice does nothing
+func (apiCfg apiConfig) likeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/chirps/{id}/like")
+	apiCfg.setChirpLiked(w, r, true)
+}
+
+// DELETE /api/chirps/{id}/like
+// remove the authenticated user's like from a chirp
+func (apiCfg apiConfig) unlikeChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/chirps/{id}/like")
+	apiCfg.setChirpLiked(w, r, false)
+}
+
+// used by likeChirpHandler and unlikeChirpHandler
+// responds with the chirp id, its like count and whether the user now likes it
+func (apiCfg apiConfig) setChirpLiked(w http.ResponseWriter, r *http.Request, liked bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var likeCount int
+	if liked {
+		var newLike bool
+		likeCount, newLike, err = apiCfg.db.LikeChirp(userId, chirpId)
+		if err == nil && newLike {
+			chirp, _ := apiCfg.db.GetChirp(chirpId)
+			apiCfg.bus.Publish(events.Event{
+				Type:     events.ChirpLiked,
+				Actor_id: userId,
+				Chirp:    chirp,
+			})
+		}
+	} else {
+		likeCount, err = apiCfg.db.UnlikeChirp(userId, chirpId)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	type retVal struct {
+		Chirp_id   int  `json:"chirp_id"`
+		Like_count int  `json:"like_count"`
+		Liked      bool `json:"liked"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{
+		Chirp_id:   chirpId,
+		Like_count: likeCount,
+		Liked:      liked,
+	})
+}
+
+// GET /api/chirps/{id}/likes
+// list the users who liked a chirp, most recent first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readChirpLikesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/chirps/{id}/likes")
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	likers, err := apiCfg.db.GetChirpLikers(chirpId, apiCfg.getOptionalUserId(r), limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	users := []publicUser{}
+	for _, liker := range likers {
+		users = append(users, newPublicUser(liker))
+	}
+
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+// GET /api/users/{id}/likes
+// list the chirps a user liked, most recent like first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readUserLikesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/{id}/likes")
+	userId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	viewerId := apiCfg.getOptionalUserId(r)
+	chirps, err := apiCfg.db.GetLikedChirps(userId, viewerId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newChirpResponses(chirps, viewerId))
+}
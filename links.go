@@ -0,0 +1,113 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"chirpy/unfurl"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// how long fetching a single link preview can take
+const linkPreviewTimeout = 5 * time.Second
+
+// how many links can wait to be previewed, links found while the queue is full are skipped
+const linkPreviewQueueSize = 256
+
+// linkPreviewer fetches the previews of links in new and edited chirps in the background
+// so posting a chirp never waits on someone else's web server
+type linkPreviewer struct {
+	unfurler *unfurl.Unfurler
+	queue    chan string
+	mux      *sync.Mutex
+	// urls queued or being fetched, so a link chirped many times at once is only fetched once
+	pending map[string]bool
+}
+
+func newLinkPreviewer(unfurler *unfurl.Unfurler) *linkPreviewer {
+	return &linkPreviewer{
+		unfurler: unfurler,
+		queue:    make(chan string, linkPreviewQueueSize),
+		mux:      &sync.Mutex{},
+		pending:  make(map[string]bool),
+	}
+}
+
+// queues a url to be previewed, unless it already is
+func (previewer *linkPreviewer) enqueue(url string) {
+	previewer.mux.Lock()
+	defer previewer.mux.Unlock()
+
+	if previewer.pending[url] {
+		return
+	}
+	select {
+	case previewer.queue <- url:
+		previewer.pending[url] = true
+	default:
+		log.Printf("link preview queue is full, skipping %s", url)
+	}
+}
+
+func (previewer *linkPreviewer) done(url string) {
+	previewer.mux.Lock()
+	defer previewer.mux.Unlock()
+
+	delete(previewer.pending, url)
+}
+
+// queues the links of every new or edited chirp that don't have a fresh preview,
+// and starts workers to fetch them
+func (apiCfg apiConfig) startLinkPreviews(workers int) {
+	apiCfg.bus.Subscribe(func(event events.Event) {
+		if event.Type != events.ChirpCreated && event.Type != events.ChirpEdited {
+			return
+		}
+		for _, entity := range event.Chirp.Entities {
+			if entity.Type == database.EntityLink && apiCfg.db.NeedsLinkPreview(entity.Text) {
+				apiCfg.linkPreviewer.enqueue(entity.Text)
+			}
+		}
+	})
+
+	for i := 0; i < workers; i++ {
+		go apiCfg.runLinkPreviewer()
+	}
+}
+
+// fetches queued links and stores their previews, failures are stored too so they aren't retried right away
+// meant to be run as a goroutine
+func (apiCfg apiConfig) runLinkPreviewer() {
+	for url := range apiCfg.linkPreviewer.queue {
+		ctx, cancel := context.WithTimeout(context.Background(), linkPreviewTimeout)
+		card, err := apiCfg.linkPreviewer.unfurler.Unfurl(ctx, url)
+		cancel()
+
+		preview := database.LinkPreview{Url: url, Fetched_at: time.Now()}
+		if err != nil {
+			log.Printf("link %s could not be previewed: %v", url, err)
+			preview.Error = err.Error()
+		} else {
+			preview.Type = card.Type
+			preview.Title = card.Title
+			preview.Description = card.Description
+			preview.Image_url = card.Image
+			preview.Site_name = card.Site_name
+		}
+		apiCfg.db.SaveLinkPreview(preview)
+		apiCfg.linkPreviewer.done(url)
+	}
+}
+
+// removes the previews of links that are gone from every chirp, every interval
+// meant to be run as a goroutine
+func (apiCfg apiConfig) purgeLinkPreviews(interval time.Duration) {
+	for {
+		if purged := apiCfg.db.PurgeLinkPreviews(); purged > 0 {
+			log.Printf("removed %d link previews", purged)
+		}
+		time.Sleep(interval)
+	}
+}
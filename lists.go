@@ -0,0 +1,267 @@
+package main
+
+import (
+	"chirpy/database"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// listResponse is how lists are sent back to their owner
+type listResponse struct {
+	database.List
+	Member_count int `json:"member_count"`
+}
+
+func (apiCfg apiConfig) newListResponse(list database.List) listResponse {
+	return listResponse{List: list, Member_count: apiCfg.db.GetListMemberCount(list.Id)}
+}
+
+// POST /api/lists
+// create a list for the authenticated user
+func (apiCfg apiConfig) createListHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/lists")
+	apiCfg.saveList(w, r, 0)
+}
+
+// PUT /api/lists/{id}
+// rename one of the authenticated user's lists or change its description
+func (apiCfg apiConfig) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: PUT /api/lists/{id}")
+	_, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+	apiCfg.saveList(w, r, listId)
+}
+
+// used by createListHandler and updateListHandler
+// listId 0 creates a new list
+func (apiCfg apiConfig) saveList(w http.ResponseWriter, r *http.Request, listId int) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	params := database.List{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("could not decode your list JSON"))
+		return
+	}
+	params.Id = listId
+	params.Owner_id = userId
+
+	if listId != 0 {
+		if _, err := apiCfg.db.GetList(listId, userId); err != nil {
+			respondWithError(w, http.StatusNotFound, err)
+			return
+		}
+	}
+	list, err := apiCfg.db.SaveList(params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := http.StatusOK
+	if listId == 0 {
+		status = http.StatusCreated
+	}
+	respondWithJSON(w, status, apiCfg.newListResponse(list))
+}
+
+// GET /api/lists
+// the authenticated user's lists, in the order they were created
+func (apiCfg apiConfig) readListsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/lists")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	lists := []listResponse{}
+	for _, list := range apiCfg.db.GetLists(userId) {
+		lists = append(lists, apiCfg.newListResponse(list))
+	}
+
+	respondWithJSON(w, http.StatusOK, lists)
+}
+
+// GET /api/lists/{id}
+// a single list of the authenticated user
+func (apiCfg apiConfig) readListHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/lists/{id}")
+	userId, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := apiCfg.db.GetList(listId, userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newListResponse(list))
+}
+
+// DELETE /api/lists/{id}
+// delete one of the authenticated user's lists
+func (apiCfg apiConfig) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/lists/{id}")
+	userId, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+
+	if err := apiCfg.db.DeleteList(listId, userId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, nil)
+}
+
+// POST /api/lists/{id}/members
+// put a user on one of the authenticated user's lists
+func (apiCfg apiConfig) addListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/lists/{id}/members")
+	userId, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+
+	params := struct {
+		User_id int `json:"user_id"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("could not decode your JSON"))
+		return
+	}
+
+	if _, err := apiCfg.db.GetList(listId, userId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	if err := apiCfg.db.AddListMember(listId, userId, params.User_id); err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	apiCfg.respondWithListMembership(w, listId, params.User_id, true)
+}
+
+// DELETE /api/lists/{id}/members/{user_id}
+// take a user off one of the authenticated user's lists
+func (apiCfg apiConfig) removeListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/lists/{id}/members/{user_id}")
+	userId, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+
+	memberId, err := strconv.Atoi(chi.URLParam(r, "user_id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	if err := apiCfg.db.RemoveListMember(listId, userId, memberId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	apiCfg.respondWithListMembership(w, listId, memberId, false)
+}
+
+// used by addListMemberHandler and removeListMemberHandler
+// responds with the list, the user and whether they are on it now
+func (apiCfg apiConfig) respondWithListMembership(w http.ResponseWriter, listId, memberId int, member bool) {
+	type retVal struct {
+		List_id      int  `json:"list_id"`
+		User_id      int  `json:"user_id"`
+		Member       bool `json:"member"`
+		Member_count int  `json:"member_count"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{
+		List_id:      listId,
+		User_id:      memberId,
+		Member:       member,
+		Member_count: apiCfg.db.GetListMemberCount(listId),
+	})
+}
+
+// GET /api/lists/{id}/members
+// the users on one of the authenticated user's lists, most recently added first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/lists/{id}/members")
+	userId, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	members, err := apiCfg.db.GetListMembers(listId, userId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	users := []publicUser{}
+	for _, member := range members {
+		users = append(users, newPublicUser(member))
+	}
+
+	respondWithJSON(w, http.StatusOK, users)
+}
+
+// GET /api/lists/{id}/timeline
+// the chirps and rechirps of the users on one of the authenticated user's lists, newest first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readListTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/lists/{id}/timeline")
+	userId, listId, ok := apiCfg.getListParams(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	entries, err := apiCfg.db.GetListTimeline(listId, userId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	apiCfg.respondWithTimeline(w, entries, userId)
+}
+
+// used by the list handlers
+// returns the authenticated user's id and the list id in the url,
+// responds with an error and returns false if either is missing
+func (apiCfg apiConfig) getListParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return 0, 0, false
+	}
+
+	listId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Errorf("list with ID %s not found", chi.URLParam(r, "id")))
+		return 0, 0, false
+	}
+
+	return userId, listId, true
+}
@@ -0,0 +1,348 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"chirpy/websocket"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// limits for connections to GET /api/live
+const (
+	liveSendBuffer     = 64 // messages queued for a client before it counts as too slow
+	liveMaxMessageSize = 4096
+	livePingInterval   = 30 * time.Second
+	liveReadTimeout    = 2 * livePingInterval
+	liveWriteTimeout   = 10 * time.Second
+)
+
+// close codes for when the user a connection was opened for can't use it anymore
+const (
+	liveCloseTokenExpired = 4001
+	liveCloseSuspended    = 4003
+)
+
+// channels a live connection can subscribe to
+const (
+	liveChannelTimeline      = "timeline"
+	liveChannelNotifications = "notifications"
+	liveChannelThread        = "thread"
+)
+
+// liveClientMessage is a message from the client
+// {"type": "subscribe", "channel": "thread", "chirp_id": 3}
+type liveClientMessage struct {
+	Type     string `json:"type"` // subscribe or unsubscribe
+	Channel  string `json:"channel"`
+	Chirp_id int    `json:"chirp_id"` // for the thread channel
+}
+
+// liveServerMessage is a message to the client
+type liveServerMessage struct {
+	Type     string      `json:"type"` // subscribed, unsubscribed, event or error
+	Channel  string      `json:"channel,omitempty"`
+	Chirp_id int         `json:"chirp_id,omitempty"`
+	Event    string      `json:"event,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// liveConnection is one client connected to GET /api/live
+type liveConnection struct {
+	apiCfg apiConfig
+	userId int
+	conn   *websocket.Conn
+	send   chan liveServerMessage
+
+	mux           *sync.Mutex
+	closed        bool
+	closeCode     int
+	closeReason   string
+	timeline      bool
+	notifications bool
+	threads       map[int]bool
+}
+
+// GET /api/live
+// WebSocket endpoint pushing timeline chirps, notifications and thread replies as they happen
+// browsers can't set headers on websockets, so the access token can also be given as the `token` query parameter
+func (apiCfg apiConfig) liveHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/live")
+	tokenString := r.URL.Query().Get("token")
+	if tokenString == "" {
+		tokenString, _ = getAuthTokenFromHeader(r)
+	}
+	userId, expiresAt, err := apiCfg.authenticateAccessToken(tokenString)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r, liveMaxMessageSize)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	live := &liveConnection{
+		apiCfg:  apiCfg,
+		userId:  userId,
+		conn:    conn,
+		send:    make(chan liveServerMessage, liveSendBuffer),
+		mux:     &sync.Mutex{},
+		threads: make(map[int]bool),
+	}
+
+	unsubscribe := apiCfg.bus.Subscribe(live.handleEvent)
+	defer unsubscribe()
+
+	// the connection is only as good as the token it was opened with
+	expiry := time.AfterFunc(time.Until(expiresAt), func() {
+		live.close(liveCloseTokenExpired, "access token expired")
+	})
+	defer expiry.Stop()
+
+	go live.writeLoop()
+	live.readLoop()
+	live.close(websocket.CloseNormal, "")
+}
+
+// reads subscribe and unsubscribe messages until the client goes away
+func (live *liveConnection) readLoop() {
+	// the write loop pings every livePingInterval, the pongs keep the connection open
+	live.conn.SetReadTimeout(liveReadTimeout)
+	for {
+		_, data, err := live.conn.ReadMessage()
+		if err != nil {
+			if !errors.Is(err, websocket.ErrClosed) {
+				log.Println(err)
+			}
+			return
+		}
+
+		message := liveClientMessage{}
+		if err := json.Unmarshal(data, &message); err != nil {
+			live.enqueue(liveServerMessage{Type: "error", Error: "could not decode your message JSON"})
+			continue
+		}
+		live.handleMessage(message)
+	}
+}
+
+// applies a client message to the connection's subscriptions and acknowledges it
+func (live *liveConnection) handleMessage(message liveClientMessage) {
+	subscribe := message.Type == "subscribe"
+	if !subscribe && message.Type != "unsubscribe" {
+		live.enqueue(liveServerMessage{Type: "error", Error: "type must be subscribe or unsubscribe"})
+		return
+	}
+
+	live.mux.Lock()
+	switch message.Channel {
+	case liveChannelTimeline:
+		live.timeline = subscribe
+	case liveChannelNotifications:
+		live.notifications = subscribe
+	case liveChannelThread:
+		root, err := live.apiCfg.db.GetChirp(message.Chirp_id)
+		if err == nil && !live.apiCfg.db.CanSeeChirp(root, live.userId) {
+			err = fmt.Errorf("chirp with ID %d not found", message.Chirp_id)
+		}
+		if subscribe && err != nil {
+			live.mux.Unlock()
+			live.enqueue(liveServerMessage{Type: "error", Channel: message.Channel, Chirp_id: message.Chirp_id, Error: err.Error()})
+			return
+		}
+		if subscribe {
+			live.threads[message.Chirp_id] = true
+		} else {
+			delete(live.threads, message.Chirp_id)
+		}
+	default:
+		live.mux.Unlock()
+		live.enqueue(liveServerMessage{Type: "error", Error: "channel must be timeline, notifications or thread"})
+		return
+	}
+	live.mux.Unlock()
+
+	ack := liveServerMessage{Type: message.Type + "d", Channel: message.Channel}
+	if message.Channel == liveChannelThread {
+		ack.Chirp_id = message.Chirp_id
+	}
+	live.enqueue(ack)
+}
+
+// called by the event bus with every event, sends the client the ones it subscribed to
+// must not block, a client that can't keep up is disconnected
+func (live *liveConnection) handleEvent(event events.Event) {
+	live.mux.Lock()
+	timeline := live.timeline
+	notifications := live.notifications
+	threads := []int{}
+	for chirpId := range live.threads {
+		threads = append(threads, chirpId)
+	}
+	live.mux.Unlock()
+
+	switch event.Type {
+	case events.UserSuspended:
+		if event.User_id == live.userId {
+			live.close(liveCloseSuspended, "account suspended")
+		}
+		return
+	case events.NotificationCreated:
+		if notifications && event.User_id == live.userId {
+			live.enqueue(liveServerMessage{Type: "event", Channel: liveChannelNotifications, Event: event.Type, Data: event.Notification})
+		}
+		return
+	case events.ChirpCreated, events.ChirpEdited, events.ChirpDeleted, events.ChirpRechirped, events.PollVoted:
+	default:
+		return
+	}
+
+	// nothing by users the viewer blocked or was blocked by, or that moderators hid
+	// deletions always go out, hiding a chirp is announced as one
+	if live.apiCfg.db.IsBlocked(live.userId, event.Chirp.Author_id) {
+		return
+	}
+	if event.Type != events.ChirpDeleted && !live.apiCfg.db.CanSeeChirp(event.Chirp, live.userId) {
+		return
+	}
+	// not even the ids of followers-only chirps or chirps by protected users go to anyone else
+	if event.Type == events.ChirpDeleted &&
+		(event.Chirp.Visibility == database.VisibilityFollowers || live.apiCfg.db.IsProtected(event.Chirp.Author_id)) &&
+		event.Chirp.Author_id != live.userId && !live.apiCfg.db.IsFollowing(live.userId, event.Chirp.Author_id) {
+		return
+	}
+	data := live.chirpEventData(event)
+	if data == nil {
+		return
+	}
+
+	// rechirps show up on the timeline because of who rechirped, not who wrote the chirp
+	// muted users are left out of it either way
+	source := event.Chirp.Author_id
+	if event.Type == events.ChirpRechirped {
+		source = event.Actor_id
+	}
+	if timeline && (source == live.userId || live.apiCfg.db.IsFollowing(live.userId, source)) &&
+		!live.apiCfg.db.IsMuted(live.userId, source) && !live.apiCfg.db.IsMuted(live.userId, event.Chirp.Author_id) {
+		live.enqueue(liveServerMessage{Type: "event", Channel: liveChannelTimeline, Event: event.Type, Data: data})
+	}
+
+	if event.Type == events.ChirpRechirped {
+		return
+	}
+	for _, rootId := range threads {
+		if live.apiCfg.db.IsInThread(event.Chirp, rootId) {
+			live.enqueue(liveServerMessage{Type: "event", Channel: liveChannelThread, Chirp_id: rootId, Event: event.Type, Data: data})
+		}
+	}
+}
+
+// the data sent along with a chirp event, nil if there is nothing the viewer may see
+func (live *liveConnection) chirpEventData(event events.Event) interface{} {
+	switch event.Type {
+	case events.PollVoted:
+		// live tallies, only for viewers who can see the results
+		poll := live.apiCfg.newPollResponse(event.Chirp, live.userId)
+		if poll == nil || !poll.Results_visible {
+			return nil
+		}
+		return struct {
+			Id   int           `json:"id"`
+			Poll *pollResponse `json:"poll"`
+		}{Id: event.Chirp.Id, Poll: poll}
+	case events.ChirpDeleted:
+		return struct {
+			Id        int `json:"id"`
+			Author_id int `json:"author_id"`
+		}{Id: event.Chirp.Id, Author_id: event.Chirp.Author_id}
+	case events.ChirpRechirped:
+		return timelineItem{
+			chirpResponse: live.apiCfg.newChirpResponse(event.Chirp, live.userId),
+			Rechirped_by:  event.Actor_id,
+			Timeline_at:   event.At,
+		}
+	}
+	return live.apiCfg.newChirpResponse(event.Chirp, live.userId)
+}
+
+// queues a message for the client without blocking
+// if the client's queue is full it is too slow to keep up and gets disconnected,
+// it should reconnect and catch up with the regular endpoints
+func (live *liveConnection) enqueue(message liveServerMessage) {
+	live.mux.Lock()
+	defer live.mux.Unlock()
+
+	if live.closed {
+		return
+	}
+	select {
+	case live.send <- message:
+	default:
+		live.closeLocked(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+// ends the connection, the write loop sends the close frame
+func (live *liveConnection) close(code int, reason string) {
+	live.mux.Lock()
+	defer live.mux.Unlock()
+	live.closeLocked(code, reason)
+}
+
+// caller must hold live.mux
+func (live *liveConnection) closeLocked(code int, reason string) {
+	if live.closed {
+		return
+	}
+	live.closed = true
+	live.closeCode = code
+	live.closeReason = reason
+	close(live.send)
+}
+
+// writes queued messages to the client and pings it so dead connections get noticed
+// sends the close frame once the connection is closed
+func (live *liveConnection) writeLoop() {
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case message, ok := <-live.send:
+			live.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if !ok {
+				live.mux.Lock()
+				code, reason := live.closeCode, live.closeReason
+				live.mux.Unlock()
+				live.conn.WriteClose(code, reason)
+				return
+			}
+			data, err := json.Marshal(message)
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if err := live.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				live.close(websocket.CloseNormal, "")
+				live.conn.Close()
+				return
+			}
+		case <-ping.C:
+			live.conn.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+			if err := live.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				live.close(websocket.CloseNormal, "")
+				live.conn.Close()
+				return
+			}
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package main
 
 import (
+	"chirpy/blobstore"
+	"chirpy/contentfilter"
 	"chirpy/database"
+	"chirpy/events"
+	"chirpy/ranking"
+	"chirpy/trends"
+	"chirpy/unfurl"
 	"encoding/json"
 	"errors"
 	"flag"
@@ -21,19 +27,47 @@ import (
 )
 
 type apiConfig struct {
-	fileserverHits int
-	db             *database.DB
-	jwtSecret      string
-	polkaApiSecret string
+	fileserverHits             int
+	db                         *database.DB
+	jwtSecret                  string
+	polkaApiSecret             string
+	adminApiKey                string
+	accountDeletionGracePeriod time.Duration
+	exportDir                  string
+	blobs                      blobstore.BlobStore
+	bus                        *events.Bus
+	stream                     *chirpStream
+	scheduler                  *scheduler
+	trends                     *trends.Aggregator
+	// rankers for the ranked timeline, users are split between them by id
+	rankers       []ranking.Ranker
+	linkPreviewer *linkPreviewer
 }
 
+// returned when a suspended user tries to log in or use their tokens
+var errAccountSuspended = errors.New("your account is suspended")
+
 type errorBody struct {
 	Error string `json:"error"`
 }
 
+// noPasswordUser is a user as shown to themselves, and to the moderators
 type noPasswordUser struct {
-	Id    int    `json:"id"`
-	Email string `json:"email"`
+	Id     int    `json:"id"`
+	Email  string `json:"email"`
+	Handle string `json:"handle,omitempty"`
+	Bio    string `json:"bio,omitempty"`
+
+	Is_protected bool `json:"is_protected"`
+}
+
+// publicUser is a user as shown to everyone else, without their email
+type publicUser struct {
+	Id     int    `json:"id"`
+	Handle string `json:"handle,omitempty"`
+	Bio    string `json:"bio,omitempty"`
+
+	Is_protected bool `json:"is_protected"`
 }
 
 // allows cross origin requests
@@ -50,6 +84,26 @@ func middlewareCors(next http.Handler) http.Handler {
 	})
 }
 
+// how big a request body can be, the JSON bodies are small and uploads set their own limit
+const maxRequestBodySize = 64 << 10
+
+// limits the size of request bodies, so a client can't make the server read and decode megabytes of JSON
+// image uploads are let through, uploadMediaHandler limits them itself
+func middlewareLimitBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/api/media" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.ContentLength > maxRequestBodySize {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body is too big, the limit is %d KB", maxRequestBodySize>>10))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
 // metrics - counting landing page server hits
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -98,6 +152,10 @@ func (apiCfg apiConfig) readChirpsHandler(w http.ResponseWriter, r *http.Request
 
 	orderScheme := "asc" // default order is ascending
 
+	// who is looking, if anyone, for the viewer specific fields like `liked`
+	// and to leave out the chirps of users they blocked or were blocked by
+	viewerId := apiCfg.getOptionalUserId(r)
+
 	// see if "sort" param present
 	tmp := r.URL.Query().Get("sort")
 	if tmp == "desc" {
@@ -114,13 +172,14 @@ func (apiCfg apiConfig) readChirpsHandler(w http.ResponseWriter, r *http.Request
 			log.Println("no user/author with that id")
 			return
 		}
-		respondWithJSON(w, 200, apiCfg.db.GetChirpsByAuthor(authorIdInt, orderScheme))
+		chirps := apiCfg.db.GetChirpsByAuthor(authorIdInt, orderScheme, viewerId)
+		respondWithJSON(w, 200, apiCfg.newChirpResponses(chirps, viewerId))
 		return
 	}
 
 	// return all chirps if optional author_id param not provided
-	allChirps := apiCfg.db.GetChirps(orderScheme)
-	respondWithJSON(w, 200, allChirps)
+	allChirps := apiCfg.db.GetChirps(orderScheme, viewerId)
+	respondWithJSON(w, 200, apiCfg.newChirpResponses(allChirps, viewerId))
 }
 
 // GET /api/chirps/{id}
@@ -138,8 +197,14 @@ func (apiCfg apiConfig) readOneChirpHandler(w http.ResponseWriter, r *http.Reque
 		respondWithError(w, 404, err)
 		return
 	}
+	// blocked users don't get to see each other's chirps, and only the author sees a hidden one
+	viewerId := apiCfg.getOptionalUserId(r)
+	if !apiCfg.db.CanSeeChirp(chirp, viewerId) {
+		respondWithError(w, 404, fmt.Errorf("chirp with ID %d not found", id))
+		return
+	}
 	// respond with found chirp matching the given id
-	respondWithJSON(w, 200, chirp)
+	respondWithJSON(w, 200, apiCfg.newChirpResponse(chirp, viewerId))
 }
 
 // POST /api/chirps
@@ -148,18 +213,10 @@ func (apiCfg apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Reques
 	log.Println("Request: POST /api/chirps")
 
 	// first authenticate user
-	_, token, err := apiCfg.getJWTAndValidate(r)
+	authorId, err := apiCfg.getAuthenticatedUserId(r)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, errors.New("invalid token"))
-		log.Println("invalid token")
-		return
-	}
-
-	// get the user id
-	userId, err := token.Claims.GetSubject()
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, errors.New("no id in JWT subject"))
-		log.Println("no id in JWT subject")
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
 		return
 	}
 
@@ -173,16 +230,14 @@ func (apiCfg apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// attach author_id to the chirp
-	authorId, err := strconv.Atoi(userId)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, errors.New("invalid userid in JWT"))
-		log.Println("invalid userid in JWT")
-		return
-	}
 	params.Author_id = authorId
 
 	// create the chirp
-	newChirp, err := apiCfg.db.CreateChirp(params)
+	newChirp, err := apiCfg.publishChirp(params)
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, err)
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusBadRequest, err)
 		log.Println(err)
@@ -190,31 +245,88 @@ func (apiCfg apiConfig) createChirpHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// respond with acknowledgement that chirp was created
-	respondWithJSON(w, 201, newChirp)
+	respondWithJSON(w, 201, apiCfg.newChirpResponse(newChirp, authorId))
 }
 
-// DELETE /api/chirps/{id}
-// delete a chirp by its id, authenticated endpoint
-func (apiCfg apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("DELETE /api/chirps/{id}")
-	// first authenticate user
-	_, token, err := apiCfg.getJWTAndValidate(r)
+// used by createChirpHandler
+// creates a chirp and lets the rest of the server know about it
+func (apiCfg apiConfig) publishChirp(chirp database.Chirp) (database.Chirp, error) {
+	newChirp, err := apiCfg.db.CreateChirp(chirp)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, errors.New("invalid token"))
-		log.Println("invalid token")
+		return newChirp, err
+	}
+
+	apiCfg.announceChirp(newChirp)
+	return newChirp, nil
+}
+
+// used by publishChirp and for drafts
+// lets the rest of the server know about a new chirp
+func (apiCfg apiConfig) announceChirp(chirp database.Chirp) {
+	apiCfg.bus.Publish(events.Event{
+		Type:     events.ChirpCreated,
+		Actor_id: chirp.Author_id,
+		Chirp:    chirp,
+	})
+}
+
+// PUT /api/chirps/{id}
+// edit the body of a chirp, authenticated endpoint
+// only the author can edit a chirp, the new body follows the same rules as a new chirp
+func (apiCfg apiConfig) updateChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: PUT /api/chirps/{id}")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
 		return
 	}
 
-	// get the user id
-	userIdString, err := token.Claims.GetSubject()
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, errors.New("no id in JWT subject"))
-		log.Println("no id in JWT subject")
+		respondWithError(w, 404, err)
 		return
 	}
-	userId, err := strconv.Atoi(userIdString)
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
+		respondWithError(w, http.StatusInternalServerError, errors.New("could not decode your chirp JSON"))
+		return
+	}
+
+	updatedChirp, err := apiCfg.db.UpdateChirp(chirpId, userId, params.Body)
+	if errors.Is(err, database.ErrNotAuthor) {
+		respondWithError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	apiCfg.bus.Publish(events.Event{
+		Type:     events.ChirpEdited,
+		Actor_id: userId,
+		Chirp:    updatedChirp,
+	})
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newChirpResponse(updatedChirp, userId))
+}
+
+// DELETE /api/chirps/{id}
+// delete a chirp by its id, authenticated endpoint
+func (apiCfg apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("DELETE /api/chirps/{id}")
+	// first authenticate user
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
 		log.Println(err)
 		return
 	}
@@ -227,7 +339,12 @@ func (apiCfg apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 	// make sure chirp's author_id is the same as the JWT's id
-	if userId != chirpId {
+	chirp, err := apiCfg.db.GetChirp(chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	if userId != chirp.Author_id {
 		respondWithError(w, http.StatusForbidden, errors.New("you are not the author of that chirp"))
 		return
 	}
@@ -239,6 +356,12 @@ func (apiCfg apiConfig) deleteChirpHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	apiCfg.bus.Publish(events.Event{
+		Type:     events.ChirpDeleted,
+		Actor_id: userId,
+		Chirp:    chirp,
+	})
+
 	respondWithJSON(w, http.StatusOK, nil)
 }
 
@@ -305,15 +428,43 @@ func isPasswordStrong(password string) bool {
 	return hasSpecial
 }
 
-// used in createNewUserHandler and updateUserHandler
+// used in createNewUserHandler, updateUserHandler and the moderation queue
 // remove the password entry from a user struct, return noPasswordUser struct
 func removePasswordFromUser(user database.User) noPasswordUser {
 	return noPasswordUser{
-		Id:    user.Id,
-		Email: user.Email,
+		Id:     user.Id,
+		Email:  user.Email,
+		Handle: user.Handle,
+		Bio:    user.Bio,
+
+		Is_protected: user.Is_protected,
+	}
+}
+
+// used wherever other users are listed or shown
+// keeps only what anyone may see of a user
+func newPublicUser(user database.User) publicUser {
+	return publicUser{
+		Id:     user.Id,
+		Handle: user.Handle,
+		Bio:    user.Bio,
+
+		Is_protected: user.Is_protected,
 	}
 }
 
+// used in createNewUserHandler and updateUserHandler
+// checks that a handle is well formed and not taken by anyone other than the given user
+func (apiCfg apiConfig) checkHandleAvailable(handle string, userId int) error {
+	if err := database.ValidateHandle(handle); err != nil {
+		return err
+	}
+	if owner, err := apiCfg.db.GetUserByHandle(handle); err == nil && owner.Id != userId {
+		return errors.New("handle is already in use")
+	}
+	return nil
+}
+
 // POST /api/users
 // create a new user
 // returns noPassUser, fields: (id, email )
@@ -337,6 +488,14 @@ func (apiCfg apiConfig) createNewUserHandler(w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	// handles are optional, but have to be unique
+	if params.Handle != "" {
+		if err := apiCfg.checkHandleAvailable(params.Handle, 0); err != nil {
+			respondWithError(w, http.StatusNotAcceptable, err)
+			return
+		}
+	}
+
 	// check password strength
 	if !isPasswordStrong(params.Password) {
 		respondWithError(w, http.StatusNotAcceptable, errors.New("password is not strong"))
@@ -404,6 +563,21 @@ func (apiCfg apiConfig) authenticateUserHandler(w http.ResponseWriter, r *http.R
 
 	// user entered the right password
 
+	if foundUser.IsSuspended() {
+		respondWithError(w, http.StatusForbidden, errAccountSuspended)
+		return
+	}
+
+	// logging in during the deletion grace period cancels the deletion
+	if foundUser.IsDeactivated() {
+		foundUser, err = apiCfg.db.ReactivateUser(foundUser.Id)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err)
+			return
+		}
+		log.Printf("user %d logged in during deletion grace period, account restored", foundUser.Id)
+	}
+
 	// create the JWT with expiration time either given from the user or using a default value
 	// create access and refresh tokens
 
@@ -434,6 +608,7 @@ func (apiCfg apiConfig) authenticateUserHandler(w http.ResponseWriter, r *http.R
 	type retVal struct {
 		Id            int    `json:"id"`
 		Email         string `json:"email"`
+		Handle        string `json:"handle,omitempty"`
 		Is_chirpy_red bool   `json:"is_chirpy_red"`
 		Token         string `json:"token"`         // access token
 		Refresh_token string `json:"refresh_token"` // refresh token
@@ -442,6 +617,7 @@ func (apiCfg apiConfig) authenticateUserHandler(w http.ResponseWriter, r *http.R
 	respondWithJSON(w, 200, retVal{
 		Id:            foundUser.Id,
 		Email:         foundUser.Email,
+		Handle:        foundUser.Handle,
 		Is_chirpy_red: foundUser.Is_chirpy_red,
 		Token:         completeAccessToken,
 		Refresh_token: completeRefreshToken,
@@ -506,58 +682,129 @@ func (apiCfg apiConfig) getJWTAndValidate(r *http.Request) (string, *jwt.Token,
 	return tokenString, token, nil
 }
 
-// PUT /api/users
-// update a user's email and password
-// authenticated endpoint
-func (apiCfg apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("Request: PUT /api/users")
-	// retrieve the validated JWT token
-	_, token, err := apiCfg.getJWTAndValidate(r)
+// used by authenticated endpoints
+// validates the access token in the "Authorization" header and returns the id of its user
+// tokens of users that no longer exist or are pending deletion are rejected
+func (apiCfg apiConfig) getAuthenticatedUserId(r *http.Request) (int, error) {
+	tokenString, err := getAuthTokenFromHeader(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err)
-		log.Println(err)
-		return
+		return 0, errors.New("invalid token")
 	}
 
-	// check the issuer to see if whether access vs refresh token
-	issuer, err := token.Claims.GetIssuer()
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, errors.New("could not get issuer from token"))
-		log.Println("could not get issuer from token")
-		return
+	userId, _, err := apiCfg.authenticateAccessToken(tokenString)
+	return userId, err
+}
+
+// used by getAuthenticatedUserId and endpoints that can't use the "Authorization" header (websockets)
+// validates an access token, returns the id of its user and when the token expires
+func (apiCfg apiConfig) authenticateAccessToken(tokenString string) (int, time.Time, error) {
+	token, err := apiCfg.validateToken(tokenString)
+	if err != nil || token == nil {
+		return 0, time.Time{}, errors.New("invalid token")
 	}
 
 	// reject if not an access token
-	if issuer != "chirpy-access" {
-		respondWithError(w, http.StatusUnauthorized, errors.New("not access token"))
-		log.Println("expected access token, got refresh token")
-		return
+	issuer, err := token.Claims.GetIssuer()
+	if err != nil || issuer != "chirpy-access" {
+		return 0, time.Time{}, errors.New("not access token")
 	}
 
 	// get the user id from the token
-	userId := token.Claims.(*jwt.RegisteredClaims).Subject
-	userIdInt, _ := strconv.Atoi(userId)
+	userIdString, err := token.Claims.GetSubject()
+	if err != nil {
+		return 0, time.Time{}, errors.New("no id in JWT subject")
+	}
+	userId, err := strconv.Atoi(userIdString)
+	if err != nil {
+		return 0, time.Time{}, errors.New("invalid userid in JWT")
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return 0, time.Time{}, errors.New("no expiration time in JWT")
+	}
+
+	// make sure the account is still around
+	user, err := apiCfg.db.GetUser(userId)
+	if err != nil || user.IsDeactivated() {
+		return 0, time.Time{}, errors.New("account does not exist or is pending deletion")
+	}
+	if user.IsSuspended() {
+		return 0, time.Time{}, errAccountSuspended
+	}
+
+	return userId, expiresAt.Time, nil
+}
 
-	// get the user from the db
-	foundUser, err := apiCfg.db.GetUser(userIdInt)
+// PUT /api/users
+// update a user's email and password
+// authenticated endpoint
+func (apiCfg apiConfig) updateUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: PUT /api/users")
+	// retrieve the user id from the validated access token
+	userIdInt, err := apiCfg.getAuthenticatedUserId(r)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, errors.New("could not find user"))
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
 		return
 	}
 
 	// decode the new user data from JSON into go struct
 	decoder := json.NewDecoder(r.Body)
-	params := database.User{}
+	// every field is a pointer so leaving one out can be told apart from clearing it
+	params := struct {
+		Email        *string `json:"email"`
+		Password     *string `json:"password"`
+		Handle       *string `json:"handle"`
+		Bio          *string `json:"bio"`
+		Is_protected *bool   `json:"is_protected"`
+	}{}
 	err = decoder.Decode(&params)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, errors.New("error decoding given body"))
 		return
 	}
 
-	// update the user
-	foundUser.Email = params.Email
-	foundUser.Password = params.Password
-	updatedUser := apiCfg.db.UpdateUser(foundUser)
+	update := database.UserUpdate{Bio: params.Bio, Is_protected: params.Is_protected}
+	if params.Email != nil {
+		if strings.TrimSpace(*params.Email) == "" {
+			respondWithError(w, http.StatusNotAcceptable, errors.New("email can't be empty"))
+			return
+		}
+		update.Email = params.Email
+	}
+	if params.Password != nil {
+		if !isPasswordStrong(*params.Password) {
+			respondWithError(w, http.StatusNotAcceptable, errors.New("password is not strong"))
+			return
+		}
+		hashedPassBytes, err := bcrypt.GenerateFromPassword([]byte(*params.Password), 13)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, err)
+			return
+		}
+		hashedPass := string(hashedPassBytes)
+		update.Hashed_password = &hashedPass
+	}
+	// the handle only changes if a new one was given
+	if params.Handle != nil && *params.Handle != "" {
+		if err := database.ValidateHandle(*params.Handle); err != nil {
+			respondWithError(w, http.StatusNotAcceptable, err)
+			return
+		}
+		update.Handle = params.Handle
+	}
+
+	// apply all the changes at once, so nothing else that changed the user in the meantime is overwritten
+	updatedUser, err := apiCfg.db.UpdateUser(userIdInt, update)
+	if errors.Is(err, database.ErrEmailInUse) || errors.Is(err, database.ErrHandleInUse) {
+		respondWithError(w, http.StatusNotAcceptable, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
 
 	// remove the hashed password before sending back
 	removedPassUser := removePasswordFromUser(updatedUser)
@@ -600,8 +847,22 @@ func (apiCfg apiConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// refresh token ok, create a new access token
+	// refresh token ok, make sure its user still has an active account
 	userId := token.Claims.(*jwt.RegisteredClaims).Subject
+	userIdInt, err := strconv.Atoi(userId)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, errors.New("invalid userid in JWT"))
+		return
+	}
+	if user, err := apiCfg.db.GetUser(userIdInt); err != nil || user.IsDeactivated() {
+		respondWithError(w, http.StatusUnauthorized, errors.New("account does not exist or is pending deletion"))
+		return
+	} else if user.IsSuspended() {
+		respondWithError(w, http.StatusForbidden, errAccountSuspended)
+		return
+	}
+
+	// create a new access token
 
 	newAccessToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
 		Issuer:    "chirpy-access",
@@ -705,12 +966,38 @@ func (apiCfg apiConfig) polkaWebhooksHandler(w http.ResponseWriter, r *http.Requ
 	respondWithJSON(w, http.StatusOK, nil)
 }
 
+// publicFileSystem only opens the site's own files: index.html and assets/
+// everything else in the directory, like the db and data exports, is reported as missing
+type publicFileSystem struct {
+	http.FileSystem
+}
+
+func (fs publicFileSystem) Open(name string) (http.File, error) {
+	if name != "/" && name != "/index.html" && name != "/assets" && !strings.HasPrefix(name, "/assets/") {
+		return nil, os.ErrNotExist
+	}
+	return fs.FileSystem.Open(name)
+}
+
 func main() {
 	filepathRoot := "."
 	databaseFile := "database.json"
+	exportDir := "exports"
+	uploadsDir := "uploads"
 	godotenv.Load() // load .env
 	jwtSecret := os.Getenv("JWT_SECRET")
 	polkaAPIKeySecret := os.Getenv("POLKA_KEY")
+	adminAPIKey := os.Getenv("ADMIN_KEY")
+
+	// how long a deleted account can still be restored, defaults to 30 days
+	accountDeletionGracePeriod := 30 * 24 * time.Hour
+	if gracePeriod := os.Getenv("ACCOUNT_DELETION_GRACE_PERIOD"); gracePeriod != "" {
+		parsed, err := time.ParseDuration(gracePeriod)
+		if err != nil {
+			log.Fatalf("invalid ACCOUNT_DELETION_GRACE_PERIOD: %v", err)
+		}
+		accountDeletionGracePeriod = parsed
+	}
 
 	// if in debug mode, delete the database.json file if it exists (reset db)
 	dbg := flag.Bool("debug", false, "Enable debug mode")
@@ -729,16 +1016,101 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
-	apiCfg := &apiConfig{
-		fileserverHits: 0,
-		db:             db,
-		jwtSecret:      jwtSecret,
-		polkaApiSecret: polkaAPIKeySecret,
+
+	// the content filter rules, reloaded whenever the file changes
+	contentFilterFile := "content_filter.json"
+	if path := os.Getenv("CONTENT_FILTER_CONFIG"); path != "" {
+		contentFilterFile = path
 	}
+	contentFilter, err := contentfilter.NewReloader(contentFilterFile)
+	if err != nil {
+		log.Fatalf("invalid content filter config: %v", err)
+	}
+	go contentFilter.Watch(5*time.Second, nil)
+	db.SetContentFilter(contentFilter)
+
+	// how long chirps can be, defaults to 140 characters and 280 for Chirpy Red users
+	chirpMaxLength, redChirpMaxLength := 140, 280
+	if maxLength := os.Getenv("CHIRP_MAX_LENGTH"); maxLength != "" {
+		chirpMaxLength, err = strconv.Atoi(maxLength)
+		if err != nil || chirpMaxLength < 1 {
+			log.Fatalf("invalid CHIRP_MAX_LENGTH: %s", maxLength)
+		}
+	}
+	if maxLength := os.Getenv("CHIRPY_RED_CHIRP_MAX_LENGTH"); maxLength != "" {
+		redChirpMaxLength, err = strconv.Atoi(maxLength)
+		if err != nil || redChirpMaxLength < 1 {
+			log.Fatalf("invalid CHIRPY_RED_CHIRP_MAX_LENGTH: %s", maxLength)
+		}
+	}
+	db.SetChirpLengthLimits(chirpMaxLength, redChirpMaxLength)
+
+	// where uploaded images are stored, outside of the source tree and of what the fileserver serves
+	if dir := os.Getenv("UPLOADS_DIR"); dir != "" {
+		uploadsDir = dir
+	}
+	blobs, err := blobstore.NewDiskStore(uploadsDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db.SetBlobStore(blobs)
+
+	// which rankers the ranked timeline tries out, comma separated, defaults to weighted
+	timelineRankers := []ranking.Ranker{ranking.DefaultWeighted}
+	if names := os.Getenv("TIMELINE_RANKERS"); names != "" {
+		timelineRankers = []ranking.Ranker{}
+		for _, name := range strings.Split(names, ",") {
+			ranker, ok := ranking.Get(strings.TrimSpace(name))
+			if !ok {
+				log.Fatalf("invalid TIMELINE_RANKERS: unknown ranker %q, choose from %s", name, strings.Join(ranking.Names(), ", "))
+			}
+			timelineRankers = append(timelineRankers, ranker)
+		}
+	}
+
+	apiCfg := &apiConfig{
+		fileserverHits:             0,
+		db:                         db,
+		jwtSecret:                  jwtSecret,
+		polkaApiSecret:             polkaAPIKeySecret,
+		adminApiKey:                adminAPIKey,
+		accountDeletionGracePeriod: accountDeletionGracePeriod,
+		exportDir:                  exportDir,
+		blobs:                      blobs,
+		bus:                        events.NewBus(),
+		scheduler:                  newScheduler(),
+		trends:                     trends.NewAggregator(trends.DefaultConfig),
+		rankers:                    timelineRankers,
+		linkPreviewer:              newLinkPreviewer(unfurl.New(unfurl.NewSafeClient(linkPreviewTimeout))),
+	}
+
+	// notify users when someone interacts with them
+	apiCfg.startNotifier()
+
+	// push chirps to clients of GET /api/stream
+	apiCfg.stream = apiCfg.newChirpStream()
+
+	// count hashtags and words for GET /api/trends
+	apiCfg.startTrends()
+
+	// fetch previews of the links in chirps
+	apiCfg.startLinkPreviews(4)
+
+	// finish any data exports interrupted by the last shutdown
+	apiCfg.resumeExports()
+
+	// hard delete accounts once their deletion grace period is over
+	go apiCfg.purgeDeletedAccounts(time.Hour)
+	go apiCfg.purgeUnattachedMedia(time.Hour)
+	go apiCfg.purgeExpiredExports(time.Hour)
+	go apiCfg.purgeLinkPreviews(time.Hour)
+	go apiCfg.runScheduler()
+	go apiCfg.refreshTrends(time.Minute)
 
 	// chi router -- use it to stop extra HTTP methods from working, restrict to GETs
 	r := chi.NewRouter()
-	r.Mount("/", apiCfg.middlewareMetricsInc(http.FileServer(http.Dir(filepathRoot))))
+	r.Use(middlewareLimitBody)
+	r.Mount("/", apiCfg.middlewareMetricsInc(http.FileServer(publicFileSystem{http.Dir(filepathRoot)})))
 
 	// ------------ api ---------------
 	// api router
@@ -750,13 +1122,104 @@ func main() {
 
 	apiRouter.Post("/chirps", apiCfg.createChirpHandler)        // create new chirps
 	apiRouter.Get("/chirps", apiCfg.readChirpsHandler)          // get all chirps
+	apiRouter.Put("/chirps/{id}", apiCfg.updateChirpHandler)    // edit a chirp
 	apiRouter.Delete("/chirps/{id}", apiCfg.deleteChirpHandler) // delete a chirp
 	apiRouter.Get("/chirps/{id}", apiCfg.readOneChirpHandler)   // read a single chirp
 
-	apiRouter.Post("/users", apiCfg.createNewUserHandler)       // create a new User
-	apiRouter.Put("/users", apiCfg.updateUserHandler)           // update a User
-	apiRouter.Post("/refresh", apiCfg.refreshTokenHandler)      // create new access token using a refresh token
-	apiRouter.Post("/revoke", apiCfg.revokeRefreshTokenHandler) // revoke a refresh token
+	apiRouter.Post("/chirps/{id}/like", apiCfg.likeChirpHandler)      // like a chirp
+	apiRouter.Delete("/chirps/{id}/like", apiCfg.unlikeChirpHandler)  // unlike a chirp
+	apiRouter.Get("/chirps/{id}/likes", apiCfg.readChirpLikesHandler) // users who liked a chirp
+	apiRouter.Get("/users/{id}/likes", apiCfg.readUserLikesHandler)   // chirps a user liked
+
+	apiRouter.Post("/chirps/{id}/rechirp", apiCfg.rechirpHandler)       // share a chirp with your followers
+	apiRouter.Delete("/chirps/{id}/rechirp", apiCfg.undoRechirpHandler) // undo a rechirp
+
+	apiRouter.Post("/users/{id}/follow", apiCfg.followUserHandler)      // follow a user
+	apiRouter.Delete("/users/{id}/follow", apiCfg.unfollowUserHandler)  // unfollow a user
+	apiRouter.Get("/users/{id}/followers", apiCfg.readFollowersHandler) // users following a user
+	apiRouter.Get("/users/{id}/following", apiCfg.readFollowingHandler) // users a user follows
+	apiRouter.Get("/timeline", apiCfg.readTimelineHandler)              // your home timeline
+
+	apiRouter.Get("/users/me/follow_requests", apiCfg.readFollowRequestsHandler)                 // users asking to follow you
+	apiRouter.Get("/users/me/follow_requests/sent", apiCfg.readSentFollowRequestsHandler)        // protected users you asked to follow
+	apiRouter.Post("/users/me/follow_requests/{id}/approve", apiCfg.approveFollowRequestHandler) // let a user follow you
+	apiRouter.Post("/users/me/follow_requests/{id}/reject", apiCfg.rejectFollowRequestHandler)   // turn a user down
+
+	apiRouter.Get("/recommendations/users", apiCfg.readRecommendedUsersHandler)                // users you might want to follow
+	apiRouter.Post("/recommendations/users/{id}/dismiss", apiCfg.dismissRecommendationHandler) // stop suggesting a user
+
+	apiRouter.Post("/chirps/{id}/bookmark", apiCfg.bookmarkChirpHandler)     // save a chirp for later
+	apiRouter.Delete("/chirps/{id}/bookmark", apiCfg.unbookmarkChirpHandler) // remove a bookmark
+	apiRouter.Get("/users/me/bookmarks", apiCfg.readBookmarksHandler)        // chirps you bookmarked
+
+	apiRouter.Post("/chirps/{id}/pin", apiCfg.pinChirpHandler)     // pin your chirp to your profile
+	apiRouter.Delete("/chirps/{id}/pin", apiCfg.unpinChirpHandler) // unpin your chirp
+	apiRouter.Get("/users/{id}", apiCfg.readProfileHandler)        // a user's profile with their pinned chirps
+
+	apiRouter.Post("/lists", apiCfg.createListHandler)                                // create a list of users
+	apiRouter.Get("/lists", apiCfg.readListsHandler)                                  // your lists
+	apiRouter.Get("/lists/{id}", apiCfg.readListHandler)                              // a single list
+	apiRouter.Put("/lists/{id}", apiCfg.updateListHandler)                            // rename a list
+	apiRouter.Delete("/lists/{id}", apiCfg.deleteListHandler)                         // delete a list
+	apiRouter.Post("/lists/{id}/members", apiCfg.addListMemberHandler)                // put a user on a list
+	apiRouter.Get("/lists/{id}/members", apiCfg.readListMembersHandler)               // users on a list
+	apiRouter.Delete("/lists/{id}/members/{user_id}", apiCfg.removeListMemberHandler) // take a user off a list
+	apiRouter.Get("/lists/{id}/timeline", apiCfg.readListTimelineHandler)             // chirps by the users on a list
+
+	apiRouter.Post("/users/{id}/block", apiCfg.blockUserHandler)     // block a user
+	apiRouter.Delete("/users/{id}/block", apiCfg.unblockUserHandler) // unblock a user
+	apiRouter.Post("/users/{id}/mute", apiCfg.muteUserHandler)       // mute a user
+	apiRouter.Delete("/users/{id}/mute", apiCfg.unmuteUserHandler)   // unmute a user
+	apiRouter.Get("/users/me/blocks", apiCfg.readBlocksHandler)      // users you blocked
+	apiRouter.Get("/users/me/mutes", apiCfg.readMutesHandler)        // users you muted
+
+	apiRouter.Post("/chirps/{id}/report", apiCfg.reportChirpHandler) // report a chirp to the moderators
+	apiRouter.Post("/users/{id}/report", apiCfg.reportUserHandler)   // report an account to the moderators
+
+	apiRouter.Get("/hashtags/{tag}/chirps", apiCfg.readHashtagChirpsHandler) // chirps with a hashtag
+	apiRouter.Get("/search", apiCfg.searchHandler)                           // full text search over chirps
+	apiRouter.Get("/trends", apiCfg.readTrendsHandler)                       // hashtags and words trending right now
+
+	apiRouter.Get("/chirps/{id}/replies", apiCfg.readRepliesHandler) // replies to a chirp
+
+	apiRouter.Post("/chirps/{id}/poll/votes", apiCfg.votePollHandler) // vote on a chirp's poll
+
+	apiRouter.Post("/drafts", apiCfg.createDraftHandler)               // save a draft or schedule a chirp
+	apiRouter.Get("/drafts", apiCfg.readDraftsHandler)                 // your drafts and scheduled chirps
+	apiRouter.Get("/drafts/{id}", apiCfg.readDraftHandler)             // a single draft
+	apiRouter.Put("/drafts/{id}", apiCfg.updateDraftHandler)           // edit or reschedule a draft
+	apiRouter.Delete("/drafts/{id}", apiCfg.deleteDraftHandler)        // delete a draft, cancels it if scheduled
+	apiRouter.Post("/drafts/{id}/publish", apiCfg.publishDraftHandler) // publish a draft right away
+
+	apiRouter.Post("/media", apiCfg.uploadMediaHandler)                      // upload an image to attach to a chirp
+	apiRouter.Get("/media/{id}", apiCfg.readMediaHandler)                    // an uploaded image
+	apiRouter.Get("/media/{id}/thumbnail", apiCfg.readMediaThumbnailHandler) // a smaller version of an uploaded image
+
+	apiRouter.Get("/stream", apiCfg.streamHandler) // live stream of chirps (Server-Sent Events)
+	apiRouter.Get("/live", apiCfg.liveHandler)     // live timelines, notifications and threads (WebSocket)
+
+	apiRouter.Get("/notifications", apiCfg.readNotificationsHandler)                         // your notifications
+	apiRouter.Post("/notifications/read", apiCfg.markAllNotificationsReadHandler)            // mark all notifications read
+	apiRouter.Post("/notifications/{id}/read", apiCfg.markNotificationReadHandler)           // mark one notification read
+	apiRouter.Get("/notifications/preferences", apiCfg.readNotificationPreferencesHandler)   // which notifications you get
+	apiRouter.Put("/notifications/preferences", apiCfg.updateNotificationPreferencesHandler) // choose which notifications you get
+
+	apiRouter.Post("/conversations", apiCfg.createConversationHandler)             // start a private conversation
+	apiRouter.Get("/conversations", apiCfg.readConversationsHandler)               // your conversations
+	apiRouter.Get("/conversations/{id}", apiCfg.readConversationHandler)           // a single conversation
+	apiRouter.Get("/conversations/{id}/messages", apiCfg.readMessagesHandler)      // messages in a conversation
+	apiRouter.Post("/conversations/{id}/messages", apiCfg.sendMessageHandler)      // send a message
+	apiRouter.Post("/conversations/{id}/read", apiCfg.markConversationReadHandler) // mark a conversation read
+
+	apiRouter.Post("/users", apiCfg.createNewUserHandler)      // create a new User
+	apiRouter.Put("/users", apiCfg.updateUserHandler)          // update a User
+	apiRouter.Delete("/users/me", apiCfg.deleteAccountHandler) // delete your own account
+
+	apiRouter.Post("/users/me/exports", apiCfg.createExportHandler)                // start exporting your data
+	apiRouter.Get("/users/me/exports/{id}", apiCfg.readExportHandler)              // status of a data export
+	apiRouter.Get("/users/me/exports/{id}/download", apiCfg.downloadExportHandler) // download a finished data export
+	apiRouter.Post("/refresh", apiCfg.refreshTokenHandler)                         // create new access token using a refresh token
+	apiRouter.Post("/revoke", apiCfg.revokeRefreshTokenHandler)                    // revoke a refresh token
 
 	apiRouter.Post("/login", apiCfg.authenticateUserHandler) // authenticate User
 
@@ -771,6 +1234,18 @@ func main() {
 	// metrics: number of serve requests
 	adminRouter.Get("/metrics", apiCfg.metricsHandlerFunc)
 
+	// moderation, needs the admin api key
+	adminRouter.Group(func(moderationRouter chi.Router) {
+		moderationRouter.Use(apiCfg.middlewareAdminAuth)
+		moderationRouter.Get("/reports", apiCfg.readReportsHandler)                      // the moderation queue
+		moderationRouter.Get("/reports/{id}", apiCfg.readReportHandler)                  // a single report
+		moderationRouter.Post("/reports/{id}/resolve", apiCfg.resolveReportHandler)      // act on a report
+		moderationRouter.Post("/chirps/{id}/unhide", apiCfg.unhideChirpHandler)          // undo hiding a chirp
+		moderationRouter.Post("/users/{id}/suspend", apiCfg.suspendUserHandler)          // suspend a user
+		moderationRouter.Post("/users/{id}/unsuspend", apiCfg.unsuspendUserHandler)      // lift a suspension
+		moderationRouter.Get("/moderation/actions", apiCfg.readModerationActionsHandler) // the audit trail
+	})
+
 	// wrap the main chi router with a handler function that allows CORS
 	corsMux := middlewareCors(r)
 
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"chirpy/blobstore"
+	"chirpy/database"
+	"chirpy/media"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// uploads that weren't attached to a chirp within this long are removed
+const unattachedMediaLifetime = 24 * time.Hour
+
+// mediaResponse is how uploads are sent back to clients
+type mediaResponse struct {
+	Id            int    `json:"id"`
+	Content_type  string `json:"content_type"`
+	Width         int    `json:"width"`
+	Height        int    `json:"height"`
+	Size          int    `json:"size"`
+	Url           string `json:"url"`
+	Thumbnail_url string `json:"thumbnail_url"`
+	Chirp_id      int    `json:"chirp_id,omitempty"`
+}
+
+func newMediaResponse(media database.Media) mediaResponse {
+	return mediaResponse{
+		Id:            media.Id,
+		Content_type:  media.Content_type,
+		Width:         media.Width,
+		Height:        media.Height,
+		Size:          media.Size,
+		Url:           fmt.Sprintf("/api/media/%d", media.Id),
+		Thumbnail_url: fmt.Sprintf("/api/media/%d/thumbnail", media.Id),
+		Chirp_id:      media.Chirp_id,
+	}
+}
+
+// POST /api/media
+// upload an image to attach to a chirp, authenticated endpoint
+// the image is the `file` field of a multipart/form-data body
+func (apiCfg apiConfig) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/media")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	// room for the rest of the form on top of the image
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxUploadSize+64<<10)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		if errors.As(err, &tooBig) {
+			respondWithError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("image is too big, the limit is %d MB", media.MaxUploadSize>>20))
+			return
+		}
+		respondWithError(w, http.StatusBadRequest, errors.New("the image must be sent as the file field of a multipart form"))
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, media.MaxUploadSize+1))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("could not read the image"))
+		return
+	}
+
+	img, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) {
+		respondWithError(w, http.StatusUnsupportedMediaType, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	stored, err := apiCfg.storeMedia(userId, img)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, errors.New("could not store the image"))
+		log.Println(err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, newMediaResponse(stored))
+}
+
+// used by uploadMediaHandler
+// puts the image and its thumbnail in the blob store and records them
+// keys are random so they can't be guessed from the media id
+func (apiCfg apiConfig) storeMedia(userId int, img media.Image) (database.Media, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return database.Media{}, err
+	}
+	name := hex.EncodeToString(random)
+
+	record := database.Media{
+		Owner_id:               userId,
+		Content_type:           img.Content_type,
+		Width:                  img.Width,
+		Height:                 img.Height,
+		Size:                   len(img.Data),
+		Key:                    name + mediaExtension(img.Content_type),
+		Thumbnail_key:          name + "-thumb" + mediaExtension(img.Thumbnail_content_type),
+		Thumbnail_content_type: img.Thumbnail_content_type,
+	}
+
+	if err := apiCfg.blobs.Put(record.Key, bytes.NewReader(img.Data)); err != nil {
+		return database.Media{}, err
+	}
+	if err := apiCfg.blobs.Put(record.Thumbnail_key, bytes.NewReader(img.Thumbnail)); err != nil {
+		apiCfg.blobs.Delete(record.Key)
+		return database.Media{}, err
+	}
+
+	stored, err := apiCfg.db.CreateMedia(record)
+	if err != nil {
+		apiCfg.blobs.Delete(record.Key)
+		apiCfg.blobs.Delete(record.Thumbnail_key)
+		return database.Media{}, err
+	}
+	return stored, nil
+}
+
+func mediaExtension(contentType string) string {
+	switch contentType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/png":
+		return ".png"
+	case "image/gif":
+		return ".gif"
+	}
+	return ""
+}
+
+// GET /api/media/{id}
+// the uploaded image, for whoever can see the chirp it is attached to
+// an image that isn't attached yet can only be seen by its uploader
+func (apiCfg apiConfig) readMediaHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/media/{id}")
+	apiCfg.serveMedia(w, r, false)
+}
+
+// GET /api/media/{id}/thumbnail
+// a smaller version of the image, same rules as the image itself
+func (apiCfg apiConfig) readMediaThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/media/{id}/thumbnail")
+	apiCfg.serveMedia(w, r, true)
+}
+
+// used by readMediaHandler and readMediaThumbnailHandler
+func (apiCfg apiConfig) serveMedia(w http.ResponseWriter, r *http.Request, thumbnail bool) {
+	mediaId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	found, err := apiCfg.db.GetMedia(mediaId)
+	if err != nil || !apiCfg.db.CanSeeMedia(found, apiCfg.getOptionalUserId(r)) {
+		respondWithError(w, http.StatusNotFound, fmt.Errorf("media with ID %d not found", mediaId))
+		return
+	}
+
+	key, contentType := found.Key, found.Content_type
+	if thumbnail {
+		key, contentType = found.Thumbnail_key, found.Thumbnail_content_type
+	}
+	blob, err := apiCfg.blobs.Open(key)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Errorf("media with ID %d not found", mediaId))
+		log.Println(err)
+		return
+	}
+	defer blob.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	http.ServeContent(w, r, "", found.Created_at, blob)
+}
+
+// the attachments of a chirp, in order
+// attachments that were removed since are left out
+func (apiCfg apiConfig) chirpAttachments(chirp database.Chirp) []mediaResponse {
+	if len(chirp.Attachment_ids) == 0 {
+		return nil
+	}
+	attachments := []mediaResponse{}
+	for _, id := range chirp.Attachment_ids {
+		if found, err := apiCfg.db.GetMedia(id); err == nil {
+			attachments = append(attachments, newMediaResponse(found))
+		}
+	}
+	return attachments
+}
+
+// removes uploads that were never attached to a chirp, every interval
+// meant to be run as a goroutine
+func (apiCfg apiConfig) purgeUnattachedMedia(interval time.Duration) {
+	for {
+		if purged := apiCfg.db.PurgeUnattachedMedia(time.Now().Add(-unattachedMediaLifetime)); purged > 0 {
+			log.Printf("removed %d unattached uploads", purged)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// copies a blob into w, used to put uploads in data exports
+func copyBlob(blobs blobstore.BlobStore, key string, w io.Writer) error {
+	blob, err := blobs.Open(key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	_, err = io.Copy(w, blob)
+	return err
+}
@@ -0,0 +1,55 @@
+package media
+
+import "errors"
+
+var errBadGIF = errors.New("could not read the image")
+
+// gifFrameCount counts the frames of a GIF by walking its blocks, without decoding any of them
+// so a small file claiming a huge number of frames is turned down before it takes up any memory
+func gifFrameCount(data []byte) (int, error) {
+	// header and logical screen descriptor, maybe followed by the global color table
+	if len(data) < 13 || string(data[:3]) != "GIF" {
+		return 0, errBadGIF
+	}
+	i := 13
+	if packed := data[10]; packed&0x80 != 0 {
+		i += 3 << (packed&0x07 + 1)
+	}
+
+	frames := 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21: // extension: label, then sub-blocks
+			i += 2
+		case 0x2c: // image descriptor, maybe a local color table, the LZW code size, then sub-blocks
+			if i+10 > len(data) {
+				return 0, errBadGIF
+			}
+			frames++
+			packed := data[i+9]
+			i += 10
+			if packed&0x80 != 0 {
+				i += 3 << (packed&0x07 + 1)
+			}
+			i++
+		case 0x3b: // trailer
+			return frames, nil
+		default:
+			return 0, errBadGIF
+		}
+
+		// sub-blocks start with their size, the last one is empty
+		for {
+			if i >= len(data) {
+				return 0, errBadGIF
+			}
+			size := int(data[i])
+			i += 1 + size
+			if size == 0 {
+				break
+			}
+		}
+	}
+	// some encoders leave the trailer out, the decoder accepts that too
+	return frames, nil
+}
@@ -0,0 +1,220 @@
+// Package media checks uploaded images and prepares them to be served
+// images are decoded and encoded again, which strips EXIF and any other metadata, like the location a photo was taken at
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// limits for uploaded images
+const (
+	MaxUploadSize = 5 << 20 // bytes
+	MaxDimension  = 8192    // pixels, in either direction
+	MaxPixels     = 40_000_000
+	maxGIFFrames  = 300
+	// every frame of an animation is decoded at once, this caps frames × width × height
+	maxGIFPixels = 100_000_000
+	// thumbnails fit in a square this size, images that already fit aren't scaled up
+	ThumbnailSize = 320
+	jpegQuality   = 85
+)
+
+// ContentTypes that can be uploaded
+var ContentTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// ErrUnsupportedType is returned for uploads that aren't a JPEG, PNG or GIF
+var ErrUnsupportedType = errors.New("only JPEG, PNG and GIF images can be uploaded")
+
+// Image is an upload ready to be stored
+type Image struct {
+	Content_type string
+	Width        int
+	Height       int
+	// the image without its metadata
+	Data []byte
+	// a smaller version of the image, in the same format apart from GIFs which get a still PNG
+	Thumbnail              []byte
+	Thumbnail_content_type string
+}
+
+// Process checks that the data is an image Chirpy accepts and prepares it to be stored
+func Process(data []byte) (Image, error) {
+	if len(data) > MaxUploadSize {
+		return Image{}, fmt.Errorf("image is too big, the limit is %d MB", MaxUploadSize>>20)
+	}
+
+	contentType := http.DetectContentType(data)
+	if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
+		return Image{}, ErrUnsupportedType
+	}
+
+	// check the size before decoding, a small file can claim to be a huge image
+	// for GIFs this is the logical screen, which every frame has to fit in
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, errors.New("could not read the image")
+	}
+	if config.Width > MaxDimension || config.Height > MaxDimension || config.Width*config.Height > MaxPixels {
+		return Image{}, fmt.Errorf("image is too large, the limit is %dx%d pixels", MaxDimension, MaxDimension)
+	}
+
+	switch contentType {
+	case "image/jpeg":
+		return processJPEG(data)
+	case "image/png":
+		return processPNG(data)
+	default:
+		return processGIF(data, config)
+	}
+}
+
+// photos are often stored sideways with an EXIF tag saying how to turn them,
+// that tag is about to be stripped so the turning is done here
+func processJPEG(data []byte) (Image, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, errors.New("could not read the image")
+	}
+	img = orient(img, exifOrientation(data))
+
+	result := Image{Content_type: "image/jpeg", Thumbnail_content_type: "image/jpeg"}
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	if result.Data, err = encodeJPEG(img); err != nil {
+		return Image{}, err
+	}
+	if result.Thumbnail, err = encodeJPEG(thumbnail(img)); err != nil {
+		return Image{}, err
+	}
+	return result, nil
+}
+
+func processPNG(data []byte) (Image, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return Image{}, errors.New("could not read the image")
+	}
+
+	result := Image{Content_type: "image/png", Thumbnail_content_type: "image/png"}
+	result.Width, result.Height = img.Bounds().Dx(), img.Bounds().Dy()
+	if result.Data, err = encodePNG(img); err != nil {
+		return Image{}, err
+	}
+	if result.Thumbnail, err = encodePNG(thumbnail(img)); err != nil {
+		return Image{}, err
+	}
+	return result, nil
+}
+
+// animations are kept, comments and application extensions are dropped
+// the frames are counted before decoding, as all of them are decoded at once
+func processGIF(data []byte, config image.Config) (Image, error) {
+	frames, err := gifFrameCount(data)
+	if err != nil {
+		return Image{}, err
+	}
+	if frames > maxGIFFrames {
+		return Image{}, fmt.Errorf("animation has too many frames, the limit is %d", maxGIFFrames)
+	}
+	if frames*config.Width*config.Height > maxGIFPixels {
+		return Image{}, fmt.Errorf("animation is too large, the limit is %d million pixels over all of its frames", maxGIFPixels/1_000_000)
+	}
+
+	animation, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(animation.Image) == 0 {
+		return Image{}, errors.New("could not read the image")
+	}
+
+	result := Image{Content_type: "image/gif", Thumbnail_content_type: "image/png"}
+	result.Width, result.Height = animation.Config.Width, animation.Config.Height
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		return Image{}, err
+	}
+	result.Data = buf.Bytes()
+
+	// the first frame, drawn on a canvas the size of the whole animation
+	first := image.NewRGBA(image.Rect(0, 0, result.Width, result.Height))
+	draw.Draw(first, animation.Image[0].Bounds(), animation.Image[0], animation.Image[0].Bounds().Min, draw.Over)
+	if result.Thumbnail, err = encodePNG(thumbnail(first)); err != nil {
+		return Image{}, err
+	}
+	return result, nil
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	return buf.Bytes(), err
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
+
+// scales the image down to fit in a ThumbnailSize square, keeping its aspect ratio
+// every thumbnail pixel is the average of the image pixels it covers
+func thumbnail(img image.Image) image.Image {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= ThumbnailSize && height <= ThumbnailSize {
+		return img
+	}
+
+	thumbWidth, thumbHeight := ThumbnailSize, ThumbnailSize
+	if width > height {
+		thumbHeight = max(1, height*ThumbnailSize/width)
+	} else {
+		thumbWidth = max(1, width*ThumbnailSize/height)
+	}
+
+	src := toRGBA(img)
+	dst := image.NewRGBA(image.Rect(0, 0, thumbWidth, thumbHeight))
+	for y := 0; y < thumbHeight; y++ {
+		y0, y1 := y*height/thumbHeight, max((y+1)*height/thumbHeight, y*height/thumbHeight+1)
+		for x := 0; x < thumbWidth; x++ {
+			x0, x1 := x*width/thumbWidth, max((x+1)*width/thumbWidth, x*width/thumbWidth+1)
+
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					for c := 0; c < 4; c++ {
+						sum[c] += int(row[sx*4+c])
+					}
+				}
+			}
+			count := (y1 - y0) * (x1 - x0)
+			offset := y*dst.Stride + x*4
+			for c := 0; c < 4; c++ {
+				dst.Pix[offset+c] = uint8(sum[c] / count)
+			}
+		}
+	}
+	return dst
+}
+
+// copies the image into an RGBA image starting at 0,0
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, bounds.Min, draw.Src)
+	return rgba
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
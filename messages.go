@@ -0,0 +1,232 @@
+package main
+
+import (
+	"chirpy/database"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// conversationResponse is a conversation as shown to one of its participants
+type conversationResponse struct {
+	Id              int               `json:"id"`
+	Participants    []publicUser      `json:"participants"`
+	Created_at      time.Time         `json:"created_at"`
+	Last_message_at time.Time         `json:"last_message_at"`
+	Last_message    *database.Message `json:"last_message,omitempty"`
+	Unread_count    int               `json:"unread_count"`
+}
+
+// fills in the participants of a conversation
+func (apiCfg apiConfig) newConversationResponse(summary database.ConversationSummary) conversationResponse {
+	participants := []publicUser{}
+	for _, id := range summary.Participant_ids {
+		if user, err := apiCfg.db.GetUser(id); err == nil && !user.IsDeactivated() {
+			participants = append(participants, newPublicUser(user))
+		}
+	}
+
+	return conversationResponse{
+		Id:              summary.Id,
+		Participants:    participants,
+		Created_at:      summary.Created_at,
+		Last_message_at: summary.Last_message_at,
+		Last_message:    summary.Last_message,
+		Unread_count:    summary.Unread_count,
+	}
+}
+
+// POST /api/conversations
+// start a private conversation between the authenticated user and the users in `participant_ids`
+// responds with the existing conversation if those users already have one
+func (apiCfg apiConfig) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/conversations")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	type parameters struct {
+		Participant_ids []int `json:"participant_ids"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("error decoding your json"))
+		return
+	}
+
+	conversation, created, err := apiCfg.db.CreateConversation(userId, params.Participant_ids)
+	if errors.Is(err, database.ErrBlocked) {
+		respondWithError(w, http.StatusForbidden, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	summary, err := apiCfg.db.GetConversation(conversation.Id, userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	respondWithJSON(w, status, apiCfg.newConversationResponse(summary))
+}
+
+// GET /api/conversations
+// the authenticated user's conversations, most recently active first, along with their total unread count
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/conversations")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	summaries, unreadCount := apiCfg.db.GetConversations(userId, limit, offset)
+
+	type retVal struct {
+		Unread_count  int                    `json:"unread_count"`
+		Conversations []conversationResponse `json:"conversations"`
+	}
+
+	conversations := []conversationResponse{}
+	for _, summary := range summaries {
+		conversations = append(conversations, apiCfg.newConversationResponse(summary))
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{
+		Unread_count:  unreadCount,
+		Conversations: conversations,
+	})
+}
+
+// GET /api/conversations/{id}
+// a single conversation of the authenticated user
+func (apiCfg apiConfig) readConversationHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/conversations/{id}")
+	userId, conversationId, ok := apiCfg.getConversationParams(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := apiCfg.db.GetConversation(conversationId, userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newConversationResponse(summary))
+}
+
+// GET /api/conversations/{id}/messages
+// the messages in one of the authenticated user's conversations, newest first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/conversations/{id}/messages")
+	userId, conversationId, ok := apiCfg.getConversationParams(w, r)
+	if !ok {
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	messages, err := apiCfg.db.GetMessages(conversationId, userId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, messages)
+}
+
+// POST /api/conversations/{id}/messages
+// send a message to one of the authenticated user's conversations
+func (apiCfg apiConfig) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/conversations/{id}/messages")
+	userId, conversationId, ok := apiCfg.getConversationParams(w, r)
+	if !ok {
+		return
+	}
+
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err := decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("error decoding your json"))
+		return
+	}
+
+	// only participants get to find out the conversation exists
+	if _, err := apiCfg.db.GetConversation(conversationId, userId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	message, err := apiCfg.db.SendMessage(conversationId, userId, params.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusCreated, message)
+}
+
+// POST /api/conversations/{id}/read
+// mark all messages in one of the authenticated user's conversations as read
+func (apiCfg apiConfig) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/conversations/{id}/read")
+	userId, conversationId, ok := apiCfg.getConversationParams(w, r)
+	if !ok {
+		return
+	}
+
+	summary, err := apiCfg.db.MarkConversationRead(conversationId, userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newConversationResponse(summary))
+}
+
+// used by the conversation handlers
+// returns the authenticated user's id and the conversation id in the url,
+// responds with an error and returns false if either is missing
+func (apiCfg apiConfig) getConversationParams(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return 0, 0, false
+	}
+
+	conversationId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no conversation with that id"))
+		return 0, 0, false
+	}
+
+	return userId, conversationId, true
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// only lets requests with the admin api key through
+// expects format - Authorization: ApiKey <key>
+// with no ADMIN_KEY configured every request is turned away
+func (apiCfg apiConfig) middlewareAdminAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apiKeyString, err := getAuthTokenFromHeader(r)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, err)
+			return
+		}
+		// compared in constant time, so how long the check takes doesn't give the key away
+		if apiCfg.adminApiKey == "" || subtle.ConstantTimeCompare([]byte(apiKeyString), []byte(apiCfg.adminApiKey)) != 1 {
+			respondWithError(w, http.StatusUnauthorized, errors.New("invalid api key"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// reportResponse is a report along with what it is about, for the moderators
+type reportResponse struct {
+	database.Report
+	Chirp   *database.Chirp   `json:"chirp,omitempty"`
+	Message *database.Message `json:"message,omitempty"`
+	User    *noPasswordUser   `json:"user,omitempty"`
+}
+
+// looks up the reported chirp or message and user, which may have been deleted since
+func (apiCfg apiConfig) newReportResponse(report database.Report) reportResponse {
+	response := reportResponse{Report: report}
+	if report.Chirp_id != 0 {
+		if chirp, err := apiCfg.db.GetChirp(report.Chirp_id); err == nil {
+			response.Chirp = &chirp
+		}
+	}
+	if report.Message_id != 0 {
+		if message, err := apiCfg.db.GetMessage(report.Message_id); err == nil {
+			response.Message = &message
+		}
+	}
+	if user, err := apiCfg.db.GetUser(report.User_id); err == nil {
+		noPassword := removePasswordFromUser(user)
+		response.User = &noPassword
+	}
+	return response
+}
+
+// GET /admin/reports
+// the moderation queue, oldest report first
+// optional query parameter `status` is open (the default), resolved, dismissed or all
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readReportsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /admin/reports")
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = database.ReportOpen
+	case "all":
+		status = ""
+	case database.ReportOpen, database.ReportResolved, database.ReportDismissed:
+	default:
+		respondWithError(w, http.StatusBadRequest, errors.New("status must be open, resolved, dismissed or all"))
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	reports, total := apiCfg.db.GetReports(status, limit, offset)
+
+	type retVal struct {
+		Total   int              `json:"total"`
+		Reports []reportResponse `json:"reports"`
+	}
+
+	responses := []reportResponse{}
+	for _, report := range reports {
+		responses = append(responses, apiCfg.newReportResponse(report))
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{Total: total, Reports: responses})
+}
+
+// GET /admin/reports/{id}
+// a single report along with what it is about
+func (apiCfg apiConfig) readReportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /admin/reports/{id}")
+	reportId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	report, err := apiCfg.db.GetReport(reportId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newReportResponse(report))
+}
+
+// POST /admin/reports/{id}/resolve
+// act on a report: hide_chirp, delete_chirp, suspend_user or dismiss
+// closes the report, and for anything but dismiss every other open report about the same chirp or user
+func (apiCfg apiConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /admin/reports/{id}/resolve")
+	reportId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	action, ok := decodeModerationAction(w, r)
+	if !ok {
+		return
+	}
+
+	report, err := apiCfg.db.GetReport(reportId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	// the chirp as it was, for telling live clients it's gone
+	chirp, _ := apiCfg.db.GetChirp(report.Chirp_id)
+
+	action, err = apiCfg.db.ModerateReport(reportId, action)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+	apiCfg.publishModerationAction(action, chirp)
+
+	respondWithJSON(w, http.StatusOK, action)
+}
+
+// POST /admin/chirps/{id}/unhide
+// make a hidden chirp visible again
+func (apiCfg apiConfig) unhideChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /admin/chirps/{id}/unhide")
+	apiCfg.moderate(w, r, database.ModerationUnhideChirp)
+}
+
+// POST /admin/users/{id}/suspend
+// suspend a user without a report
+func (apiCfg apiConfig) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /admin/users/{id}/suspend")
+	apiCfg.moderate(w, r, database.ModerationSuspendUser)
+}
+
+// POST /admin/users/{id}/unsuspend
+// lift a user's suspension
+func (apiCfg apiConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /admin/users/{id}/unsuspend")
+	apiCfg.moderate(w, r, database.ModerationUnsuspendUser)
+}
+
+// used by the moderation handlers that aren't about a report
+// takes the action on the chirp or user in the url
+func (apiCfg apiConfig) moderate(w http.ResponseWriter, r *http.Request, actionType string) {
+	targetId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	action, ok := decodeModerationAction(w, r)
+	if !ok {
+		return
+	}
+	action.Action = actionType
+	if actionType == database.ModerationUnhideChirp {
+		action.Chirp_id = targetId
+	} else {
+		action.User_id = targetId
+	}
+
+	action, err = apiCfg.db.Moderate(action)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	apiCfg.publishModerationAction(action, database.Chirp{})
+
+	respondWithJSON(w, http.StatusOK, action)
+}
+
+// GET /admin/moderation/actions
+// the moderation audit trail, newest first
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readModerationActionsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /admin/moderation/actions")
+	limit, offset := getPaginationParams(r)
+	respondWithJSON(w, http.StatusOK, apiCfg.db.GetModerationActions(limit, offset))
+}
+
+// decodes the action, moderator and note of a moderation request
+// an empty body is fine for the endpoints that already know the action
+func decodeModerationAction(w http.ResponseWriter, r *http.Request) (database.ModerationAction, bool) {
+	type parameters struct {
+		Action    string `json:"action"`
+		Moderator string `json:"moderator"`
+		Note      string `json:"note"`
+	}
+
+	params := parameters{}
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&params); err != nil && r.ContentLength != 0 {
+		respondWithError(w, http.StatusBadRequest, errors.New("error decoding your json"))
+		return database.ModerationAction{}, false
+	}
+
+	return database.ModerationAction{
+		Action:    params.Action,
+		Moderator: params.Moderator,
+		Note:      params.Note,
+	}, true
+}
+
+// lets the rest of the server know about moderation actions that change what clients see
+func (apiCfg apiConfig) publishModerationAction(action database.ModerationAction, chirp database.Chirp) {
+	switch action.Action {
+	case database.ModerationHideChirp, database.ModerationDeleteChirp:
+		// to everyone but the author a hidden chirp is as good as deleted
+		apiCfg.bus.Publish(events.Event{
+			Type:  events.ChirpDeleted,
+			Chirp: chirp,
+		})
+	case database.ModerationSuspendUser:
+		apiCfg.bus.Publish(events.Event{
+			Type:    events.UserSuspended,
+			User_id: action.User_id,
+		})
+	}
+}
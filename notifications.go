@@ -0,0 +1,192 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// turns events into notifications for the users they concern
+// runs for as long as the server does
+func (apiCfg apiConfig) startNotifier() {
+	apiCfg.bus.Subscribe(func(event events.Event) {
+		for _, notification := range apiCfg.notificationsForEvent(event) {
+			notification, created := apiCfg.db.CreateNotification(notification)
+			if created {
+				apiCfg.bus.Publish(events.Event{
+					Type:         events.NotificationCreated,
+					Actor_id:     notification.Actor_id,
+					User_id:      notification.User_id,
+					Notification: notification,
+				})
+			}
+		}
+	})
+}
+
+// works out who should be notified about an event
+// whether they actually are depends on their preferences, see database.CreateNotification
+func (apiCfg apiConfig) notificationsForEvent(event events.Event) []database.Notification {
+	notifications := []database.Notification{}
+	newNotification := func(notificationType string, userId int) database.Notification {
+		return database.Notification{
+			User_id:    userId,
+			Type:       notificationType,
+			Actor_id:   event.Actor_id,
+			Chirp_id:   event.Chirp.Id,
+			Created_at: event.At,
+		}
+	}
+
+	switch event.Type {
+	case events.ChirpCreated:
+		// the parent's author hears about a reply once, even if they are also mentioned in it
+		notified := map[int]bool{}
+		if event.Chirp.In_reply_to != 0 {
+			if parent, err := apiCfg.db.GetChirp(event.Chirp.In_reply_to); err == nil {
+				notifications = append(notifications, newNotification(database.NotificationReply, parent.Author_id))
+				notified[parent.Author_id] = true
+			}
+		}
+		for _, entity := range event.Chirp.Entities {
+			if entity.Type == database.EntityMention && !notified[entity.User_id] {
+				notifications = append(notifications, newNotification(database.NotificationMention, entity.User_id))
+				notified[entity.User_id] = true
+			}
+		}
+	case events.ChirpLiked:
+		notifications = append(notifications, newNotification(database.NotificationLike, event.Chirp.Author_id))
+	case events.UserFollowed:
+		notification := newNotification(database.NotificationFollow, event.User_id)
+		notification.Chirp_id = 0
+		notifications = append(notifications, notification)
+	case events.FollowRequested:
+		notification := newNotification(database.NotificationFollowRequest, event.User_id)
+		notification.Chirp_id = 0
+		notifications = append(notifications, notification)
+	}
+
+	return notifications
+}
+
+// GET /api/notifications
+// the authenticated user's notifications, newest first, along with their unread count
+// optional query parameter `unread=true` only returns unread notifications
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/notifications")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+	limit, offset := getPaginationParams(r)
+	notifications, unreadCount := apiCfg.db.GetNotifications(userId, unreadOnly, limit, offset)
+
+	type retVal struct {
+		Unread_count  int                     `json:"unread_count"`
+		Notifications []database.Notification `json:"notifications"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{
+		Unread_count:  unreadCount,
+		Notifications: notifications,
+	})
+}
+
+// POST /api/notifications/{id}/read
+// mark one of the authenticated user's notifications as read
+func (apiCfg apiConfig) markNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/notifications/{id}/read")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	notificationId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	notification, err := apiCfg.db.MarkNotificationRead(userId, notificationId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, notification)
+}
+
+// POST /api/notifications/read
+// mark all of the authenticated user's notifications as read
+func (apiCfg apiConfig) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/notifications/read")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	type retVal struct {
+		Marked_read int `json:"marked_read"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{Marked_read: apiCfg.db.MarkAllNotificationsRead(userId)})
+}
+
+// GET /api/notifications/preferences
+// the types of notifications the authenticated user gets
+func (apiCfg apiConfig) readNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/notifications/preferences")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.db.GetNotificationPreferences(userId))
+}
+
+// PUT /api/notifications/preferences
+// choose the types of notifications the authenticated user gets
+// types left out of the request body keep their current setting
+func (apiCfg apiConfig) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: PUT /api/notifications/preferences")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	// decode on top of the current preferences
+	prefs := apiCfg.db.GetNotificationPreferences(userId)
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&prefs)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("error decoding your json"))
+		return
+	}
+
+	err = apiCfg.db.UpdateNotificationPreferences(userId, prefs)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, prefs)
+}
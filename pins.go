@@ -0,0 +1,105 @@
+package main
+
+import (
+	"chirpy/database"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// profileResponse is a user's profile as seen by the viewer
+type profileResponse struct {
+	publicUser
+	Follower_count  int             `json:"follower_count"`
+	Following_count int             `json:"following_count"`
+	Pinned_chirps   []chirpResponse `json:"pinned_chirps"`
+}
+
+// POST /api/chirps/{id}/pin
+// pin one of the authenticated user's chirps to the top of their profile
+func (apiCfg apiConfig) pinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/chirps/{id}/pin")
+	apiCfg.setChirpPinned(w, r, true)
+}
+
+// DELETE /api/chirps/{id}/pin
+// take one of the authenticated user's chirps off their profile
+func (apiCfg apiConfig) unpinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/chirps/{id}/pin")
+	apiCfg.setChirpPinned(w, r, false)
+}
+
+// used by pinChirpHandler and unpinChirpHandler
+// responds with the chirp id, whether it is pinned now and all of the user's pinned chirp ids
+func (apiCfg apiConfig) setChirpPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var pinnedIds []int
+	if pinned {
+		pinnedIds, err = apiCfg.db.PinChirp(userId, chirpId)
+	} else {
+		pinnedIds, err = apiCfg.db.UnpinChirp(userId, chirpId)
+	}
+	if errors.Is(err, database.ErrNotAuthor) {
+		respondWithError(w, http.StatusForbidden, err)
+		return
+	}
+	if errors.Is(err, database.ErrTooManyPins) {
+		respondWithError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	type retVal struct {
+		Chirp_id         int   `json:"chirp_id"`
+		Pinned           bool  `json:"pinned"`
+		Pinned_chirp_ids []int `json:"pinned_chirp_ids"`
+	}
+
+	if pinnedIds == nil {
+		pinnedIds = []int{}
+	}
+	respondWithJSON(w, http.StatusOK, retVal{Chirp_id: chirpId, Pinned: pinned, Pinned_chirp_ids: pinnedIds})
+}
+
+// GET /api/users/{id}
+// a user's profile: who they are, how many follow them and who they follow, and their pinned chirps
+func (apiCfg apiConfig) readProfileHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/users/{id}")
+	userId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	viewerId := apiCfg.getOptionalUserId(r)
+	profile, err := apiCfg.db.GetProfile(userId, viewerId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, profileResponse{
+		publicUser:      newPublicUser(profile.User),
+		Follower_count:  profile.Follower_count,
+		Following_count: profile.Following_count,
+		Pinned_chirps:   apiCfg.newChirpResponses(profile.Pinned_chirps, viewerId),
+	})
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// pollResponse is how a chirp's poll is sent back to clients
+// the vote counts are left out until the viewer is allowed to see them
+type pollResponse struct {
+	Options            []pollOptionResponse `json:"options"`
+	Expires_at         time.Time            `json:"expires_at"`
+	Results_visibility string               `json:"results_visibility"`
+	Closed             bool                 `json:"closed"`
+	Results_visible    bool                 `json:"results_visible"`
+	Total_votes        *int                 `json:"total_votes,omitempty"`
+	// index of the option the viewer voted for
+	Voted_option *int `json:"voted_option,omitempty"`
+}
+
+type pollOptionResponse struct {
+	Text  string `json:"text"`
+	Votes *int   `json:"votes,omitempty"`
+}
+
+// builds the poll of a chirp as seen by the viewer, nil if the chirp has no poll
+// viewerId 0 means an anonymous viewer
+func (apiCfg apiConfig) newPollResponse(chirp database.Chirp, viewerId int) *pollResponse {
+	if chirp.Poll == nil {
+		return nil
+	}
+	return newPollResponseFromTally(*chirp.Poll, apiCfg.db.GetPollTally(chirp, viewerId))
+}
+
+func newPollResponseFromTally(poll database.Poll, tally database.PollTally) *pollResponse {
+	response := &pollResponse{
+		Options:            []pollOptionResponse{},
+		Expires_at:         poll.Expires_at,
+		Results_visibility: poll.Results_visibility,
+		Closed:             tally.Closed,
+		Results_visible:    tally.Results_visible,
+	}
+	for i, text := range poll.Options {
+		option := pollOptionResponse{Text: text}
+		if tally.Results_visible && i < len(tally.Counts) {
+			option.Votes = &tally.Counts[i]
+		}
+		response.Options = append(response.Options, option)
+	}
+	if tally.Results_visible {
+		response.Total_votes = &tally.Total
+	}
+	if tally.Voted_option != -1 {
+		response.Voted_option = &tally.Voted_option
+	}
+	return response
+}
+
+// POST /api/chirps/{id}/poll/votes
+// vote on the poll of a chirp, authenticated endpoint
+// everyone gets one vote, responds with the poll including the results
+func (apiCfg apiConfig) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/chirps/{id}/poll/votes")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+	chirp, err := apiCfg.db.GetChirp(chirpId)
+	if err != nil || !apiCfg.db.CanSeeChirp(chirp, userId) {
+		respondWithError(w, http.StatusNotFound, fmt.Errorf("chirp with ID %d not found", chirpId))
+		return
+	}
+
+	params := struct {
+		Option *int `json:"option"`
+	}{}
+	if err := json.NewDecoder(r.Body).Decode(&params); err != nil || params.Option == nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("option is required"))
+		return
+	}
+
+	tally, err := apiCfg.db.VotePoll(chirpId, userId, *params.Option)
+	if errors.Is(err, database.ErrPollClosed) || errors.Is(err, database.ErrAlreadyVoted) {
+		respondWithError(w, http.StatusConflict, err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	apiCfg.bus.Publish(events.Event{
+		Type:     events.PollVoted,
+		Actor_id: userId,
+		Chirp:    chirp,
+	})
+
+	respondWithJSON(w, http.StatusCreated, newPollResponseFromTally(*chirp.Poll, tally))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"chirpy/ranking"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// how many of the newest timeline entries the ranked timeline picks from
+const maxRankedCandidates = 500
+
+// rankingDebug is how a chirp's place on the ranked timeline was worked out
+type rankingDebug struct {
+	Ranker    string             `json:"ranker"`
+	Score     float64            `json:"score"`
+	Breakdown map[string]float64 `json:"breakdown"`
+}
+
+// the ranker a user gets on the ranked timeline, users are split evenly between the configured rankers by id
+func (apiCfg apiConfig) rankerFor(userId int) ranking.Ranker {
+	return apiCfg.rankers[userId%len(apiCfg.rankers)]
+}
+
+// used by readTimelineHandler for `mode=ranked`
+// the best of the newest 500 entries of the home timeline according to the user's ranker, up to `limit` of them
+// it is a single page: the order changes between requests as chirps get older and liked,
+// so paging through it with `offset` would skip and repeat chirps, it is refused
+// optional `ranker` picks a ranker by name instead, e.g. to compare them
+// optional `debug=true` adds each chirp's score and how it was made up
+// the ranker used is in the X-Timeline-Ranker header
+func (apiCfg apiConfig) readRankedTimeline(w http.ResponseWriter, r *http.Request, userId int) {
+	ranker := apiCfg.rankerFor(userId)
+	if name := r.URL.Query().Get("ranker"); name != "" {
+		var ok bool
+		if ranker, ok = ranking.Get(name); !ok {
+			respondWithError(w, http.StatusBadRequest, fmt.Errorf("ranker must be one of %s", strings.Join(ranking.Names(), ", ")))
+			return
+		}
+	}
+	debug := r.URL.Query().Get("debug")
+	if debug != "" && debug != "true" && debug != "false" {
+		respondWithError(w, http.StatusBadRequest, errors.New("debug must be true or false"))
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	if offset > 0 {
+		respondWithError(w, http.StatusBadRequest, errors.New("the ranked timeline is a single page, offset is not supported"))
+		return
+	}
+
+	candidates, err := apiCfg.db.GetTimelineCandidates(userId, maxRankedCandidates)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	page := ranker.Rank(time.Now(), candidates)
+	if len(page) > limit {
+		page = page[:limit]
+	}
+
+	timeline := []timelineItem{}
+	for _, ranked := range page {
+		item := timelineItem{
+			chirpResponse: apiCfg.newChirpResponse(ranked.Chirp, userId),
+			Rechirped_by:  ranked.Rechirped_by,
+			Timeline_at:   ranked.At,
+		}
+		if debug == "true" {
+			item.Ranking = &rankingDebug{Ranker: ranker.Name(), Score: ranked.Score, Breakdown: ranked.Breakdown}
+		}
+		timeline = append(timeline, item)
+	}
+
+	w.Header().Set("X-Timeline-Ranker", ranker.Name())
+	respondWithJSON(w, http.StatusOK, timeline)
+}
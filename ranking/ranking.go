@@ -0,0 +1,188 @@
+// Package ranking orders home timeline chirps by how interesting they are likely to be instead of newest first
+// rankers are pluggable, so different ones can be tried out on different users
+package ranking
+
+import (
+	"chirpy/database"
+	"math"
+	"sort"
+	"time"
+)
+
+// parts of a score, see Ranked
+const (
+	Recency    = "recency"
+	Engagement = "engagement"
+	Affinity   = "affinity"
+	Diversity  = "diversity"
+)
+
+// Ranker scores timeline candidates
+// Rank returns every candidate it is given, best first
+type Ranker interface {
+	Name() string
+	Rank(now time.Time, candidates []database.TimelineCandidate) []Ranked
+}
+
+// Ranked is a candidate with its score and how the score was made up
+type Ranked struct {
+	database.TimelineCandidate
+	Score float64
+	// part of the score -> what it added, they add up to Score
+	Breakdown map[string]float64
+}
+
+// the built in rankers, by name
+var rankers = map[string]Ranker{}
+
+// Register makes a ranker available to Get under its name, replacing any ranker with the same name
+func Register(ranker Ranker) {
+	rankers[ranker.Name()] = ranker
+}
+
+// Get returns the ranker registered under a name
+func Get(name string) (Ranker, bool) {
+	ranker, ok := rankers[name]
+	return ranker, ok
+}
+
+// Names returns the names of the registered rankers, sorted
+func Names() []string {
+	names := []string{}
+	for name := range rankers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(DefaultWeighted)
+	Register(Chronological{HalfLife: DefaultWeighted.HalfLife})
+}
+
+// Weighted adds up recency, engagement and affinity with the author,
+// then holds back authors who already have chirps higher up so a single busy account doesn't take over the timeline
+type Weighted struct {
+	// a chirp's recency halves every HalfLife, counting from when it showed up on the timeline
+	HalfLife time.Duration
+	// recency is between 0 and 1, the others grow with the log of their counts
+	RecencyWeight    float64
+	EngagementWeight float64
+	AffinityWeight   float64
+	// every chirp by an author already ranked higher multiplies the score by this
+	DiversityDecay float64
+}
+
+// DefaultWeighted is the Weighted ranker used unless configured otherwise
+var DefaultWeighted = Weighted{
+	HalfLife:         6 * time.Hour,
+	RecencyWeight:    2,
+	EngagementWeight: 0.25,
+	AffinityWeight:   0.5,
+	DiversityDecay:   0.7,
+}
+
+func (weighted Weighted) Name() string {
+	return "weighted"
+}
+
+func (weighted Weighted) Rank(now time.Time, candidates []database.TimelineCandidate) []Ranked {
+	scored := []Ranked{}
+	for _, candidate := range candidates {
+		// rechirps count for more than likes and replies, they put the chirp in front of new people
+		engagement := float64(candidate.Like_count + 2*candidate.Rechirp_count + candidate.Reply_count)
+		breakdown := map[string]float64{
+			Recency:    weighted.RecencyWeight * decay(now.Sub(candidate.At), weighted.HalfLife),
+			Engagement: weighted.EngagementWeight * math.Log2(1+engagement),
+			Affinity:   weighted.AffinityWeight * math.Log2(1+float64(candidate.Author_affinity)),
+		}
+		scored = append(scored, Ranked{
+			TimelineCandidate: candidate,
+			Score:             breakdown[Recency] + breakdown[Engagement] + breakdown[Affinity],
+			Breakdown:         breakdown,
+		})
+	}
+	sortRanked(scored)
+
+	// take the best chirp one at a time, holding back the authors already taken
+	ranked := []Ranked{}
+	taken := map[int]int{}
+	for len(scored) > 0 {
+		best, bestScore := 0, math.Inf(-1)
+		for i, candidate := range scored {
+			score := candidate.Score * math.Pow(weighted.DiversityDecay, float64(taken[candidate.Chirp.Author_id]))
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+
+		chosen := scored[best]
+		chosen.Breakdown[Diversity] = bestScore - chosen.Score
+		chosen.Score = bestScore
+		roundRanked(&chosen)
+		ranked = append(ranked, chosen)
+		taken[chosen.Chirp.Author_id]++
+		scored = append(scored[:best], scored[best+1:]...)
+	}
+	return ranked
+}
+
+// Chronological ranks by recency alone, newest first like the chronological timeline,
+// for comparing the other rankers against
+type Chronological struct {
+	HalfLife time.Duration
+}
+
+func (chronological Chronological) Name() string {
+	return "chronological"
+}
+
+func (chronological Chronological) Rank(now time.Time, candidates []database.TimelineCandidate) []Ranked {
+	ranked := []Ranked{}
+	for _, candidate := range candidates {
+		recency := decay(now.Sub(candidate.At), chronological.HalfLife)
+		entry := Ranked{
+			TimelineCandidate: candidate,
+			Score:             recency,
+			Breakdown:         map[string]float64{Recency: recency},
+		}
+		roundRanked(&entry)
+		ranked = append(ranked, entry)
+	}
+	sortRanked(ranked)
+	return ranked
+}
+
+// how much something age old still counts, halving every halfLife
+func decay(age, halfLife time.Duration) float64 {
+	if age < 0 {
+		age = 0
+	}
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
+
+// best first, newest first between equal scores
+func sortRanked(ranked []Ranked) {
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if !ranked[i].At.Equal(ranked[j].At) {
+			return ranked[i].At.After(ranked[j].At)
+		}
+		return ranked[i].Chirp.Id > ranked[j].Chirp.Id
+	})
+}
+
+// rounds the score and its breakdown for showing them
+func roundRanked(ranked *Ranked) {
+	ranked.Score = round(ranked.Score)
+	for part, value := range ranked.Breakdown {
+		ranked.Breakdown[part] = round(value)
+	}
+}
+
+func round(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
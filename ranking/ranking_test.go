@@ -0,0 +1,138 @@
+package ranking
+
+import (
+	"chirpy/database"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2023, 5, 27, 20, 0, 0, 0, time.UTC)
+
+func candidate(id, authorId int, age time.Duration) database.TimelineCandidate {
+	return database.TimelineCandidate{
+		TimelineEntry: database.TimelineEntry{
+			Chirp: database.Chirp{Id: id, Author_id: authorId},
+			At:    now.Add(-age),
+		},
+	}
+}
+
+func TestWeightedRank(t *testing.T) {
+	// recency alone, halving every hour, so the scores are easy to work out
+	weighted := Weighted{HalfLife: time.Hour, RecencyWeight: 1, DiversityDecay: 0.5}
+
+	tests := []struct {
+		name       string
+		candidates []database.TimelineCandidate
+		wantIds    []int
+		wantScores []float64
+	}{
+		{
+			name: "newest first without engagement",
+			candidates: []database.TimelineCandidate{
+				candidate(1, 1, 2*time.Hour), candidate(2, 2, 0), candidate(3, 3, time.Hour),
+			},
+			wantIds:    []int{2, 3, 1},
+			wantScores: []float64{1, 0.5, 0.25},
+		},
+		{
+			name: "an author's second chirp is held back",
+			candidates: []database.TimelineCandidate{
+				candidate(1, 1, 0), candidate(2, 1, 10*time.Minute), candidate(3, 2, 30*time.Minute),
+			},
+			wantIds:    []int{1, 3, 2},
+			wantScores: []float64{1, 0.707, 0.445},
+		},
+		{
+			name: "the decay compounds with every chirp already taken",
+			candidates: []database.TimelineCandidate{
+				candidate(1, 1, 0), candidate(2, 1, 0), candidate(3, 1, 0), candidate(4, 2, 90*time.Minute),
+			},
+			wantIds:    []int{3, 2, 4, 1},
+			wantScores: []float64{1, 0.5, 0.354, 0.25},
+		},
+		{
+			name: "equal scores go to the newest chirp",
+			candidates: []database.TimelineCandidate{
+				candidate(1, 1, 0), candidate(2, 1, 0), candidate(3, 1, 0), candidate(4, 2, 2*time.Hour),
+			},
+			// chirp 1 is held back twice to 0.25, the same as chirp 4, and is newer
+			wantIds:    []int{3, 2, 1, 4},
+			wantScores: []float64{1, 0.5, 0.25, 0.25},
+		},
+		{
+			name: "equal scores and times go to the highest id",
+			candidates: []database.TimelineCandidate{
+				candidate(5, 1, time.Hour), candidate(7, 3, time.Hour), candidate(6, 2, time.Hour),
+			},
+			wantIds:    []int{7, 6, 5},
+			wantScores: []float64{0.5, 0.5, 0.5},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ranked := weighted.Rank(now, test.candidates)
+
+			ids, scores := []int{}, []float64{}
+			for _, entry := range ranked {
+				ids = append(ids, entry.Chirp.Id)
+				scores = append(scores, entry.Score)
+			}
+			if !reflect.DeepEqual(ids, test.wantIds) {
+				t.Errorf("got ids %v, want %v", ids, test.wantIds)
+			}
+			if !reflect.DeepEqual(scores, test.wantScores) {
+				t.Errorf("got scores %v, want %v", scores, test.wantScores)
+			}
+		})
+	}
+}
+
+func TestWeightedRankBreakdown(t *testing.T) {
+	candidates := []database.TimelineCandidate{candidate(1, 1, 0), candidate(2, 1, time.Hour)}
+	candidates[1].Like_count = 3
+	candidates[1].Rechirp_count = 2
+	candidates[1].Author_affinity = 7
+
+	for _, entry := range DefaultWeighted.Rank(now, candidates) {
+		sum := 0.0
+		for _, value := range entry.Breakdown {
+			sum += value
+		}
+		// each part is rounded on its own
+		if math.Abs(sum-entry.Score) > 0.002 {
+			t.Errorf("chirp %d: breakdown %v adds up to %v, not its score %v", entry.Chirp.Id, entry.Breakdown, sum, entry.Score)
+		}
+	}
+
+	ranked := DefaultWeighted.Rank(now, candidates)
+	// chirp 2 is older, but liked, rechirped and by an author the viewer interacts with
+	if ranked[0].Chirp.Id != 2 {
+		t.Fatalf("got chirp %d first, want 2", ranked[0].Chirp.Id)
+	}
+	// 2 * 0.5^(1/6) + 0.25 * log2(8) + 0.5 * log2(8)
+	if ranked[0].Score != 4.032 {
+		t.Errorf("got score %v, want 4.032", ranked[0].Score)
+	}
+	// chirp 1 is by the same author, so it is held back
+	if ranked[1].Breakdown[Diversity] != -0.6 {
+		t.Errorf("got diversity %v, want -0.6", ranked[1].Breakdown[Diversity])
+	}
+}
+
+func TestChronologicalRank(t *testing.T) {
+	candidates := []database.TimelineCandidate{
+		candidate(1, 1, 3*time.Hour), candidate(2, 1, 0), candidate(3, 2, time.Hour), candidate(4, 1, time.Hour),
+	}
+	ids := []int{}
+	for _, entry := range (Chronological{HalfLife: time.Hour}).Rank(now, candidates) {
+		ids = append(ids, entry.Chirp.Id)
+	}
+	// same author or not, like the chronological timeline
+	if want := []int{2, 4, 3, 1}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("got ids %v, want %v", ids, want)
+	}
+}
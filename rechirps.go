@@ -0,0 +1,132 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi"
+)
+
+// timelineItem is a chirp on the home timeline
+// rechirped_by is set when it is there because someone you follow rechirped it
+type timelineItem struct {
+	chirpResponse
+	Rechirped_by int       `json:"rechirped_by,omitempty"`
+	Timeline_at  time.Time `json:"timeline_at"`
+	// only on the ranked timeline in debug mode
+	Ranking *rankingDebug `json:"ranking,omitempty"`
+}
+
+// POST /api/chirps/{id}/rechirp
+// share a chirp with the authenticated user's followers
+func (apiCfg apiConfig) rechirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/chirps/{id}/rechirp")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	rechirp, err := apiCfg.db.RechirpChirp(userId, chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	chirp, _ := apiCfg.db.GetChirp(chirpId)
+	apiCfg.bus.Publish(events.Event{
+		Type:     events.ChirpRechirped,
+		Actor_id: userId,
+		Chirp:    chirp,
+		At:       rechirp.Created_at,
+	})
+
+	respondWithJSON(w, http.StatusCreated, rechirp)
+}
+
+// DELETE /api/chirps/{id}/rechirp
+// undo the authenticated user's rechirp of a chirp
+func (apiCfg apiConfig) undoRechirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: DELETE /api/chirps/{id}/rechirp")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	err = apiCfg.db.UndoRechirp(userId, chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, nil)
+}
+
+// GET /api/timeline
+// the authenticated user's home timeline, newest first
+// their own chirps and the chirps of the people they follow, plus what those people rechirped
+// optional `mode` is chronological (default) or ranked, see readRankedTimeline
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/timeline")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	switch r.URL.Query().Get("mode") {
+	case "", "chronological":
+	case "ranked":
+		apiCfg.readRankedTimeline(w, r, userId)
+		return
+	default:
+		respondWithError(w, http.StatusBadRequest, errors.New("mode must be chronological or ranked"))
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	entries, err := apiCfg.db.GetTimeline(userId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	apiCfg.respondWithTimeline(w, entries, userId)
+}
+
+// used by readTimelineHandler and readListTimelineHandler
+// responds with the timeline entries as seen by the viewer
+func (apiCfg apiConfig) respondWithTimeline(w http.ResponseWriter, entries []database.TimelineEntry, userId int) {
+	timeline := []timelineItem{}
+	for _, entry := range entries {
+		timeline = append(timeline, timelineItem{
+			chirpResponse: apiCfg.newChirpResponse(entry.Chirp, userId),
+			Rechirped_by:  entry.Rechirped_by,
+			Timeline_at:   entry.At,
+		})
+	}
+
+	respondWithJSON(w, http.StatusOK, timeline)
+}
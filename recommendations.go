@@ -0,0 +1,83 @@
+package main
+
+import (
+	"chirpy/database"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// recommendationResponse is a Recommendation as shown to the viewer, without anyone's password hash or email
+type recommendationResponse struct {
+	database.Recommendation
+	User        publicUser   `json:"user"`
+	Followed_by []publicUser `json:"followed_by"`
+}
+
+// GET /api/recommendations/users
+// users the authenticated user might want to follow, best first, with why they are suggested
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readRecommendedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/recommendations/users")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	recommendations, err := apiCfg.db.GetRecommendedUsers(userId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	responses := []recommendationResponse{}
+	for _, recommendation := range recommendations {
+		response := recommendationResponse{
+			Recommendation: recommendation,
+			User:           newPublicUser(recommendation.User),
+			Followed_by:    []publicUser{},
+		}
+		for _, user := range recommendation.Followed_by {
+			response.Followed_by = append(response.Followed_by, newPublicUser(user))
+		}
+		responses = append(responses, response)
+	}
+
+	respondWithJSON(w, http.StatusOK, responses)
+}
+
+// POST /api/recommendations/users/{id}/dismiss
+// stop suggesting a user to the authenticated user
+func (apiCfg apiConfig) dismissRecommendationHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/recommendations/users/{id}/dismiss")
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	dismissedId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, errors.New("no user with that id"))
+		return
+	}
+
+	if err := apiCfg.db.DismissRecommendation(userId, dismissedId); err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	type retVal struct {
+		User_id   int  `json:"user_id"`
+		Dismissed bool `json:"dismissed"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{User_id: dismissedId, Dismissed: true})
+}
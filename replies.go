@@ -0,0 +1,32 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// GET /api/chirps/{id}/replies
+// list the chirps replying directly to a chirp, oldest first
+// reply to a chirp by creating a chirp with `in_reply_to` set to its id
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) readRepliesHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/chirps/{id}/replies")
+	chirpId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	limit, offset := getPaginationParams(r)
+	viewerId := apiCfg.getOptionalUserId(r)
+	replies, err := apiCfg.db.GetReplies(chirpId, viewerId, limit, offset)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, apiCfg.newChirpResponses(replies, viewerId))
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"chirpy/database"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+)
+
+// POST /api/chirps/{id}/report
+// report a chirp to the moderators as the authenticated user
+func (apiCfg apiConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/chirps/{id}/report")
+	apiCfg.createReport(w, r, database.ReportTargetChirp)
+}
+
+// POST /api/users/{id}/report
+// report an account to the moderators as the authenticated user
+func (apiCfg apiConfig) reportUserHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: POST /api/users/{id}/report")
+	apiCfg.createReport(w, r, database.ReportTargetUser)
+}
+
+// used by reportChirpHandler and reportUserHandler
+// files a report about the chirp or user in the url
+// responds 201 with a new report, or 200 with the reporter's open report about the same thing
+func (apiCfg apiConfig) createReport(w http.ResponseWriter, r *http.Request, targetType string) {
+	userId, err := apiCfg.getAuthenticatedUserId(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, err)
+		log.Println(err)
+		return
+	}
+
+	targetId, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err)
+		return
+	}
+
+	type parameters struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errors.New("error decoding your json"))
+		return
+	}
+
+	report := database.Report{
+		Reporter_id: userId,
+		Target_type: targetType,
+		Reason:      params.Reason,
+		Details:     params.Details,
+	}
+	if targetType == database.ReportTargetChirp {
+		report.Chirp_id = targetId
+	} else {
+		report.User_id = targetId
+	}
+
+	report, created, err := apiCfg.db.CreateReport(report)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	respondWithJSON(w, status, report)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"chirpy/database"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// GET /api/search?q=
+// full text search over chirps
+// `q` supports plain words, "quoted phrases", #hashtags and from:handle (or from:<user id>),
+// everything in it has to match
+// optional `sort` is `relevance` (default) or `recency`
+// paginated with the optional `limit` and `offset` query parameters
+func (apiCfg apiConfig) searchHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/search")
+	query := database.ParseSearchQuery(r.URL.Query().Get("q"))
+	if query.IsEmpty() {
+		respondWithError(w, http.StatusBadRequest, errors.New("missing search query `q`"))
+		return
+	}
+
+	orderScheme := "relevance"
+	if r.URL.Query().Get("sort") == "recency" {
+		orderScheme = "recency"
+	}
+
+	limit, offset := getPaginationParams(r)
+	viewerId := apiCfg.getOptionalUserId(r)
+	chirps, total := apiCfg.db.SearchChirps(query, orderScheme, viewerId, limit, offset)
+
+	type retVal struct {
+		Total   int             `json:"total"`
+		Results []chirpResponse `json:"results"`
+	}
+
+	respondWithJSON(w, http.StatusOK, retVal{
+		Total:   total,
+		Results: apiCfg.newChirpResponses(chirps, viewerId),
+	})
+}
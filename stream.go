@@ -0,0 +1,275 @@
+package main
+
+import (
+	"chirpy/database"
+	"chirpy/events"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many past events are kept so clients can resume with Last-Event-ID
+const streamHistorySize = 1000
+
+// how many events a client can fall behind before it is disconnected
+const streamClientBuffer = 64
+
+// how often an idle stream gets a comment line so proxies don't close it
+const streamHeartbeatInterval = 15 * time.Second
+
+// sent instead of the missed events to a client resuming from an event that isn't in the history anymore,
+// e.g. one from before the server restarted, so it reloads the chirps it shows
+const streamResyncEvent = "resync"
+
+// streamEvent is a chirp event as sent to stream clients
+type streamEvent struct {
+	Id    int
+	Type  string
+	Chirp database.Chirp
+	Data  []byte // the json sent as the event's data
+}
+
+// streamFilter picks the events a client wants, zero values match everything
+type streamFilter struct {
+	authorId int
+	hashtag  string
+}
+
+// matches reports whether the event passes the filter
+func (filter streamFilter) matches(event streamEvent) bool {
+	if filter.authorId != 0 && event.Chirp.Author_id != filter.authorId {
+		return false
+	}
+	if filter.hashtag != "" {
+		for _, entity := range event.Chirp.Entities {
+			if entity.Type == database.EntityHashtag && strings.EqualFold(entity.Text, filter.hashtag) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// chirpStream fans chirp events from the event bus out to the connected stream clients
+// and remembers the most recent ones for clients that reconnect
+type chirpStream struct {
+	// identifies this run of the server, event ids are numbered from 1 again after a restart
+	// so it is part of every id sent, see eventId
+	run     string
+	mux     *sync.Mutex
+	lastId  int
+	history []streamEvent
+	clients map[chan streamEvent]streamFilter
+}
+
+// creates the stream and subscribes it to the chirp events on the bus
+func (apiCfg apiConfig) newChirpStream() *chirpStream {
+	stream := &chirpStream{
+		run:     strconv.FormatInt(time.Now().UnixNano(), 36),
+		mux:     &sync.Mutex{},
+		clients: make(map[chan streamEvent]streamFilter),
+	}
+
+	apiCfg.bus.Subscribe(func(event events.Event) {
+		var data interface{}
+		switch event.Type {
+		case events.ChirpCreated, events.ChirpEdited:
+			if !apiCfg.isStreamable(event.Chirp) {
+				return
+			}
+			data = apiCfg.newChirpResponse(event.Chirp, 0)
+		case events.ChirpDeleted:
+			if !event.Chirp.IsPublic() || apiCfg.db.IsProtected(event.Chirp.Author_id) {
+				return
+			}
+			data = struct {
+				Id        int `json:"id"`
+				Author_id int `json:"author_id"`
+			}{Id: event.Chirp.Id, Author_id: event.Chirp.Author_id}
+		default:
+			return
+		}
+
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		stream.publish(streamEvent{Type: event.Type, Chirp: event.Chirp, Data: encoded})
+	})
+
+	return stream
+}
+
+// the stream is public, chirps hidden by moderators, unlisted or followers-only chirps
+// and chirps by protected or deactivated users stay off it
+func (apiCfg apiConfig) isStreamable(chirp database.Chirp) bool {
+	return chirp.IsPublic() && apiCfg.db.CanSeeChirp(chirp, 0)
+}
+
+// drops the missed events of chirps that were deleted or can't be streamed anymore since,
+// e.g. because a moderator hid them or their author became protected
+// deletions are kept, so clients still take the chirp down
+func (apiCfg apiConfig) stillStreamable(missed []streamEvent) []streamEvent {
+	streamable := []streamEvent{}
+	for _, event := range missed {
+		if event.Type == events.ChirpCreated || event.Type == events.ChirpEdited {
+			chirp, err := apiCfg.db.GetChirp(event.Chirp.Id)
+			if err != nil || !apiCfg.isStreamable(chirp) {
+				continue
+			}
+		}
+		streamable = append(streamable, event)
+	}
+	return streamable
+}
+
+// numbers the event, stores it in the history and sends it to every client that wants it
+// clients that fell too far behind are disconnected, they can resume with Last-Event-ID
+func (stream *chirpStream) publish(event streamEvent) {
+	stream.mux.Lock()
+	defer stream.mux.Unlock()
+
+	stream.lastId++
+	event.Id = stream.lastId
+
+	stream.history = append(stream.history, event)
+	if len(stream.history) > streamHistorySize {
+		stream.history = stream.history[len(stream.history)-streamHistorySize:]
+	}
+
+	for ch, filter := range stream.clients {
+		if !filter.matches(event) {
+			continue
+		}
+		select {
+		case ch <- event:
+		default:
+			delete(stream.clients, ch)
+			close(ch)
+		}
+	}
+}
+
+// the id an event is sent with
+func (stream *chirpStream) eventId(id int) string {
+	return fmt.Sprintf("%s-%d", stream.run, id)
+}
+
+// registers a new client
+// returns its channel and the events it missed since lastEventId that match its filter,
+// or a resync event if they can't all be replayed
+func (stream *chirpStream) subscribe(filter streamFilter, lastEventId string) (chan streamEvent, []streamEvent) {
+	stream.mux.Lock()
+	defer stream.mux.Unlock()
+
+	missed := []streamEvent{}
+	if lastEventId != "" {
+		run, id, _ := strings.Cut(lastEventId, "-")
+		idInt, err := strconv.Atoi(id)
+		oldest := stream.lastId + 1
+		if len(stream.history) > 0 {
+			oldest = stream.history[0].Id
+		}
+
+		if run != stream.run || err != nil || idInt < oldest-1 || idInt > stream.lastId {
+			missed = append(missed, streamEvent{Id: stream.lastId, Type: streamResyncEvent, Data: []byte("{}")})
+		} else {
+			for _, event := range stream.history {
+				if event.Id > idInt && filter.matches(event) {
+					missed = append(missed, event)
+				}
+			}
+		}
+	}
+
+	ch := make(chan streamEvent, streamClientBuffer)
+	stream.clients[ch] = filter
+	return ch, missed
+}
+
+// removes a client, if it wasn't already dropped
+func (stream *chirpStream) unsubscribe(ch chan streamEvent) {
+	stream.mux.Lock()
+	defer stream.mux.Unlock()
+
+	if _, ok := stream.clients[ch]; ok {
+		delete(stream.clients, ch)
+		close(ch)
+	}
+}
+
+// GET /api/stream
+// Server-Sent Events stream of chirps as they are created, edited and deleted
+// optional query parameters `author_id` and `hashtag` only stream matching chirps
+// clients resume after a disconnect with the Last-Event-ID header (or `last_event_id` query parameter)
+func (apiCfg apiConfig) streamHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/stream")
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, errors.New("streaming is not supported"))
+		return
+	}
+
+	filter := streamFilter{hashtag: strings.TrimPrefix(r.URL.Query().Get("hashtag"), "#")}
+	if authorId := r.URL.Query().Get("author_id"); authorId != "" {
+		authorIdInt, err := strconv.Atoi(authorId)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, errors.New("invalid author_id"))
+			return
+		}
+		filter.authorId = authorIdInt
+	}
+
+	lastEventId := r.Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = r.URL.Query().Get("last_event_id")
+	}
+
+	ch, missed := apiCfg.stream.subscribe(filter, lastEventId)
+	defer apiCfg.stream.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	// tell the client how long to wait before reconnecting, then catch it up
+	fmt.Fprint(w, "retry: 3000\n\n")
+	for _, event := range apiCfg.stillStreamable(missed) {
+		apiCfg.stream.write(w, event)
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				// fell too far behind, the client reconnects and resumes from its last event
+				return
+			}
+			apiCfg.stream.write(w, event)
+			flusher.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writes an event in the text/event-stream format
+func (stream *chirpStream) write(w http.ResponseWriter, event streamEvent) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", stream.eventId(event.Id), event.Type, event.Data)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"chirpy/events"
+	"chirpy/trends"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// how many trends are kept from each refresh, and how many GET /api/trends returns by default
+const (
+	maxTrends     = 50
+	defaultTrends = 10
+)
+
+// feeds the trends aggregator the chirps from the last day, then every chirp event from now on
+func (apiCfg apiConfig) startTrends() {
+	for _, chirp := range apiCfg.db.GetTrendableChirps(time.Now().Add(-trends.DefaultConfig.Baseline)) {
+		apiCfg.trends.Add(chirp)
+	}
+
+	apiCfg.bus.Subscribe(func(event events.Event) {
+		switch event.Type {
+		case events.ChirpCreated, events.ChirpEdited:
+			if apiCfg.db.CanTrend(event.Chirp) {
+				apiCfg.trends.Add(event.Chirp)
+			} else {
+				apiCfg.trends.Remove(event.Chirp.Id)
+			}
+		case events.ChirpDeleted:
+			apiCfg.trends.Remove(event.Chirp.Id)
+		case events.UserSuspended:
+			apiCfg.trends.RemoveAuthor(event.User_id)
+		}
+	})
+}
+
+// works out the trends again every interval, leaving out chirps flagged as spam
+func (apiCfg apiConfig) refreshTrends(interval time.Duration) {
+	for {
+		flagged := apiCfg.db.GetSpamFlaggedChirpIds()
+		apiCfg.trends.Refresh(time.Now(), maxTrends, func(chirpId, authorId int) bool {
+			return flagged[chirpId]
+		})
+		time.Sleep(interval)
+	}
+}
+
+// GET /api/trends
+// the hashtags and words being chirped about more than usual, most trending first
+// optional query parameters `type` (hashtag or term) and `limit` (default 10, at most 50)
+// authenticated users don't see trends that only come from users they blocked, were blocked by or muted
+func (apiCfg apiConfig) readTrendsHandler(w http.ResponseWriter, r *http.Request) {
+	log.Println("Request: GET /api/trends")
+
+	trendType := r.URL.Query().Get("type")
+	if trendType != "" && trendType != trends.TypeHashtag && trendType != trends.TypeTerm {
+		respondWithError(w, http.StatusBadRequest, errors.New("type must be hashtag or term"))
+		return
+	}
+	limit := defaultTrends
+	if parsed, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && parsed > 0 {
+		limit = parsed
+	}
+	if limit > maxTrends {
+		limit = maxTrends
+	}
+
+	// everyone else shares the trends from the last refresh
+	current := apiCfg.trends.Current()
+	if viewerId := apiCfg.getOptionalUserId(r); viewerId != 0 {
+		if hidden := apiCfg.db.GetUsersHiddenFrom(viewerId); len(hidden) > 0 {
+			flagged := apiCfg.db.GetSpamFlaggedChirpIds()
+			current = apiCfg.trends.Compute(time.Now(), maxTrends, func(chirpId, authorId int) bool {
+				return flagged[chirpId] || hidden[authorId]
+			})
+		}
+	}
+
+	found := []trends.Trend{}
+	for _, trend := range current {
+		if len(found) == limit {
+			break
+		}
+		if trendType == "" || trend.Type == trendType {
+			found = append(found, trend)
+		}
+	}
+
+	respondWithJSON(w, http.StatusOK, found)
+}
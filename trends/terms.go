@@ -0,0 +1,76 @@
+package trends
+
+import (
+	"chirpy/database"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// words too short to mean anything on their own
+const minTermLength = 3
+
+// words that are in every other chirp and never say what it is about
+var stopWords = map[string]bool{}
+
+func init() {
+	for _, word := range strings.Fields(`
+		about after again all also and any are because been before being but can could did does doing
+		don't down each for from further had has have having her here hers him his how i'm into its it's
+		just like more most much not now off once only other our ours out over own same she should some
+		such than that that's the their theirs them then there these they this those through too under
+		until very was were what when where which while who whom why will with would you your yours
+		get got going gonna one really today time new day see know think make want need still way
+		lol yes yeah okay`) {
+		stopWords[word] = true
+	}
+}
+
+// the hashtags (with their #) and words a chirp is about, each only once
+// mentions, links and stop words are left out
+func chirpTerms(chirp database.Chirp) []string {
+	seen := map[string]bool{}
+	terms := []string{}
+	add := func(term string) {
+		if !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+
+	for _, entity := range chirp.Entities {
+		if entity.Type == database.EntityHashtag {
+			add("#" + strings.ToLower(entity.Text))
+		}
+	}
+
+	for _, field := range strings.Fields(chirp.Body) {
+		lower := strings.ToLower(field)
+		if strings.HasPrefix(lower, "#") || strings.HasPrefix(lower, "@") ||
+			strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+			continue
+		}
+		// keep apostrophes inside words so "don't" can be recognised as a stop word
+		words := strings.FieldsFunc(lower, func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c) && !unicode.Is(unicode.Mn, c) && c != '\''
+		})
+		for _, word := range words {
+			word = strings.Trim(word, "'")
+			if utf8.RuneCountInString(word) < minTermLength || stopWords[word] || isNumber(word) {
+				continue
+			}
+			add(word)
+		}
+	}
+
+	return terms
+}
+
+func isNumber(word string) bool {
+	for _, c := range word {
+		if !unicode.IsDigit(c) {
+			return false
+		}
+	}
+	return true
+}
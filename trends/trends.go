@@ -0,0 +1,277 @@
+// Package trends works out which hashtags and words are being chirped about more than usual
+// it is fed chirps as they are created, edited and deleted, and never looks at the db itself
+package trends
+
+import (
+	"chirpy/database"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// types of Trend
+const (
+	TypeHashtag = "hashtag"
+	TypeTerm    = "term"
+)
+
+// Trend is a hashtag or word that is being chirped about more than usual
+type Trend struct {
+	// hashtags start with #
+	Term string `json:"term"`
+	Type string `json:"type"`
+	// how many times more than usual it is being chirped about, decayed so the last few minutes count the most
+	Score float64 `json:"score"`
+	// how many different users chirped it in the window
+	Author_count int `json:"author_count"`
+	Chirp_count  int `json:"chirp_count"`
+}
+
+// Config controls how trends are worked out
+type Config struct {
+	// chirps in the last Window make up the trend
+	Window time.Duration
+	// chirps older than Window up to Baseline show what is usual, nothing older is kept
+	Baseline time.Duration
+	// how quickly chirps in the window count for less, a chirp HalfLife old counts half
+	HalfLife time.Duration
+	// chirps are grouped into buckets this long
+	Bucket time.Duration
+	// how many different users need to chirp about something for it to trend,
+	// so a single account can't make something trend
+	MinAuthors int
+}
+
+// DefaultConfig looks at the last hour against the day before it
+var DefaultConfig = Config{
+	Window:     time.Hour,
+	Baseline:   24 * time.Hour,
+	HalfLife:   20 * time.Minute,
+	Bucket:     time.Minute,
+	MinAuthors: 2,
+}
+
+// the terms of one chirp, kept so the chirp can be taken out again
+type entry struct {
+	author int
+	bucket int64
+	terms  []string
+}
+
+// Aggregator keeps count of the terms in recent chirps
+type Aggregator struct {
+	config Config
+	mux    *sync.Mutex
+	// bucket -> term -> author -> ids of their chirps with the term
+	buckets map[int64]map[string]map[int]map[int]bool
+	entries map[int]entry
+	// the last result of Refresh
+	current []Trend
+}
+
+// NewAggregator creates an Aggregator that hasn't seen any chirps yet
+func NewAggregator(config Config) *Aggregator {
+	return &Aggregator{
+		config:  config,
+		mux:     &sync.Mutex{},
+		buckets: make(map[int64]map[string]map[int]map[int]bool),
+		entries: make(map[int]entry),
+		current: []Trend{},
+	}
+}
+
+// Add counts the terms of a chirp at the time it was created
+// adding a chirp again replaces it, for edits
+// chirps older than the baseline are ignored
+func (aggregator *Aggregator) Add(chirp database.Chirp) {
+	aggregator.mux.Lock()
+	defer aggregator.mux.Unlock()
+
+	aggregator.remove(chirp.Id)
+	if time.Since(chirp.Created_at) > aggregator.config.Baseline {
+		return
+	}
+
+	bucket := chirp.Created_at.Truncate(aggregator.config.Bucket).Unix()
+	terms := chirpTerms(chirp)
+	if len(terms) == 0 {
+		return
+	}
+	aggregator.entries[chirp.Id] = entry{author: chirp.Author_id, bucket: bucket, terms: terms}
+
+	if aggregator.buckets[bucket] == nil {
+		aggregator.buckets[bucket] = make(map[string]map[int]map[int]bool)
+	}
+	for _, term := range terms {
+		if aggregator.buckets[bucket][term] == nil {
+			aggregator.buckets[bucket][term] = make(map[int]map[int]bool)
+		}
+		if aggregator.buckets[bucket][term][chirp.Author_id] == nil {
+			aggregator.buckets[bucket][term][chirp.Author_id] = make(map[int]bool)
+		}
+		aggregator.buckets[bucket][term][chirp.Author_id][chirp.Id] = true
+	}
+}
+
+// Remove stops counting a chirp, for chirps that were deleted or hidden
+func (aggregator *Aggregator) Remove(chirpId int) {
+	aggregator.mux.Lock()
+	defer aggregator.mux.Unlock()
+
+	aggregator.remove(chirpId)
+}
+
+// RemoveAuthor stops counting all of a user's chirps, for suspended users
+func (aggregator *Aggregator) RemoveAuthor(authorId int) {
+	aggregator.mux.Lock()
+	defer aggregator.mux.Unlock()
+
+	for chirpId, entry := range aggregator.entries {
+		if entry.author == authorId {
+			aggregator.remove(chirpId)
+		}
+	}
+}
+
+// caller must hold the lock
+func (aggregator *Aggregator) remove(chirpId int) {
+	entry, ok := aggregator.entries[chirpId]
+	if !ok {
+		return
+	}
+	delete(aggregator.entries, chirpId)
+
+	bucket := aggregator.buckets[entry.bucket]
+	for _, term := range entry.terms {
+		delete(bucket[term][entry.author], chirpId)
+		if len(bucket[term][entry.author]) == 0 {
+			delete(bucket[term], entry.author)
+		}
+		if len(bucket[term]) == 0 {
+			delete(bucket, term)
+		}
+	}
+	if len(bucket) == 0 {
+		delete(aggregator.buckets, entry.bucket)
+	}
+}
+
+// Refresh drops the chirps that are older than the baseline and works out the trends again
+// exclude leaves chirps out, e.g. ones flagged as spam, it can be nil
+// the result is kept for Current
+func (aggregator *Aggregator) Refresh(now time.Time, limit int, exclude func(chirpId, authorId int) bool) []Trend {
+	aggregator.mux.Lock()
+	defer aggregator.mux.Unlock()
+
+	cutoff := now.Add(-aggregator.config.Baseline).Unix()
+	for chirpId, entry := range aggregator.entries {
+		if entry.bucket < cutoff {
+			aggregator.remove(chirpId)
+		}
+	}
+
+	aggregator.current = aggregator.compute(now, limit, exclude)
+	return aggregator.current
+}
+
+// Current returns the trends from the last Refresh
+func (aggregator *Aggregator) Current() []Trend {
+	aggregator.mux.Lock()
+	defer aggregator.mux.Unlock()
+
+	return aggregator.current
+}
+
+// Compute works out the trends without keeping them, e.g. for a viewer who blocked some of the authors
+func (aggregator *Aggregator) Compute(now time.Time, limit int, exclude func(chirpId, authorId int) bool) []Trend {
+	aggregator.mux.Lock()
+	defer aggregator.mux.Unlock()
+
+	return aggregator.compute(now, limit, exclude)
+}
+
+// each author counts once per term per bucket however many chirps they posted with it,
+// a term's score is its decayed count in the window
+// divided by how often it came up in a window's worth of the baseline before it
+// caller must hold the lock
+func (aggregator *Aggregator) compute(now time.Time, limit int, exclude func(chirpId, authorId int) bool) []Trend {
+	config := aggregator.config
+	windowStart := now.Add(-config.Window)
+	baselineStart := now.Add(-config.Baseline)
+
+	recent := map[string]float64{}
+	usual := map[string]float64{}
+	authors := map[string]map[int]bool{}
+	chirps := map[string]int{}
+
+	for bucket, terms := range aggregator.buckets {
+		at := time.Unix(bucket, 0)
+		if at.Before(baselineStart) || at.After(now) {
+			continue
+		}
+		inWindow := !at.Before(windowStart)
+		weight := math.Pow(0.5, float64(now.Sub(at))/float64(config.HalfLife))
+
+		for term, byAuthor := range terms {
+			for author, chirpIds := range byAuthor {
+				count := 0
+				for chirpId := range chirpIds {
+					if exclude == nil || !exclude(chirpId, author) {
+						count++
+					}
+				}
+				if count == 0 {
+					continue
+				}
+				if !inWindow {
+					usual[term]++
+					continue
+				}
+				recent[term] += weight
+				chirps[term] += count
+				if authors[term] == nil {
+					authors[term] = map[int]bool{}
+				}
+				authors[term][author] = true
+			}
+		}
+	}
+
+	// how often the term comes up in a window, going by the rest of the baseline
+	baselineWindows := float64(config.Baseline-config.Window) / float64(config.Window)
+	trends := []Trend{}
+	for term, count := range recent {
+		if len(authors[term]) < config.MinAuthors {
+			continue
+		}
+		expected := 0.0
+		if baselineWindows > 0 {
+			expected = usual[term] / baselineWindows
+		}
+
+		trend := Trend{
+			Term:         term,
+			Type:         TypeTerm,
+			Score:        math.Round(count/(expected+1)*100) / 100,
+			Author_count: len(authors[term]),
+			Chirp_count:  chirps[term],
+		}
+		if strings.HasPrefix(term, "#") {
+			trend.Type = TypeHashtag
+		}
+		trends = append(trends, trend)
+	}
+
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Score != trends[j].Score {
+			return trends[i].Score > trends[j].Score
+		}
+		return trends[i].Term < trends[j].Term
+	})
+	if limit > 0 && len(trends) > limit {
+		trends = trends[:limit]
+	}
+	return trends
+}
@@ -0,0 +1,50 @@
+package unfurl
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	metaTagPattern   = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attributePattern = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	titlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	spacePattern     = regexp.MustCompile(`\s+`)
+)
+
+// returns the content of a page's <meta> tags by their property or name, lowercase
+// the first tag with a name wins, like browsers and crawlers do
+func metaTags(page string) map[string]string {
+	tags := map[string]string{}
+	for _, tag := range metaTagPattern.FindAllString(page, -1) {
+		attributes := map[string]string{}
+		for _, match := range attributePattern.FindAllStringSubmatch(tag, -1) {
+			attributes[strings.ToLower(match[1])] = html.UnescapeString(strings.Trim(match[2], `"'`))
+		}
+
+		name := attributes["property"]
+		if name == "" {
+			name = attributes["name"]
+		}
+		name = strings.ToLower(name)
+		if _, ok := tags[name]; name != "" && !ok {
+			tags[name] = cleanText(attributes["content"])
+		}
+	}
+	return tags
+}
+
+// the text of a page's <title>, if it has one
+func pageTitle(page string) string {
+	match := titlePattern.FindStringSubmatch(page)
+	if match == nil {
+		return ""
+	}
+	return cleanText(html.UnescapeString(match[1]))
+}
+
+// collapses runs of whitespace and newlines into single spaces
+func cleanText(text string) string {
+	return strings.TrimSpace(spacePattern.ReplaceAllString(text, " "))
+}
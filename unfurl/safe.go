@@ -0,0 +1,102 @@
+package unfurl
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// how many redirects NewSafeClient follows
+const maxRedirects = 5
+
+// ErrPrivateAddress is returned when a url leads to an address that isn't on the public internet
+var ErrPrivateAddress = errors.New("refusing to connect to a private address")
+
+// address ranges that aren't on the public internet, on top of what net.IP can tell
+var blockedNetworks = []*net.IPNet{
+	mustParseCIDR("0.0.0.0/8"),       // "this" network
+	mustParseCIDR("100.64.0.0/10"),   // carrier grade NAT
+	mustParseCIDR("192.0.0.0/24"),    // IETF protocol assignments
+	mustParseCIDR("192.0.2.0/24"),    // documentation
+	mustParseCIDR("198.18.0.0/15"),   // benchmarking
+	mustParseCIDR("198.51.100.0/24"), // documentation
+	mustParseCIDR("203.0.113.0/24"),  // documentation
+	mustParseCIDR("240.0.0.0/4"),     // reserved, and broadcast
+	mustParseCIDR("64:ff9b::/96"),    // IPv4 translation, could reach private IPv4 addresses
+	mustParseCIDR("2001:db8::/32"),   // documentation
+}
+
+// NewSafeClient creates a http client for fetching user supplied urls
+// it only connects to public addresses, checked on every connection after DNS resolution
+// so redirects and DNS records pointing at the server's own network are refused too
+func NewSafeClient(timeout time.Duration) *http.Client {
+	return newSafeClient(timeout, func(host, _ string) bool {
+		ip := net.ParseIP(host)
+		return ip != nil && IsPublicIP(ip)
+	})
+}
+
+// allowed decides which resolved addresses the client connects to, tests let their own server through
+func newSafeClient(timeout time.Duration, allowed func(host, port string) bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowed(host, port) {
+				return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// a proxy would be connected to instead of the page, so it isn't used
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("can't follow a redirect to %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// IsPublicIP checks if an address is on the public internet,
+// not loopback, private, link local, multicast or otherwise reserved
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
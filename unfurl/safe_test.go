@@ -0,0 +1,102 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	tests := []struct {
+		ip     string
+		public bool
+	}{
+		{"93.184.216.34", true},
+		{"8.8.8.8", true},
+		{"2606:4700:4700::1111", true},
+		{"127.0.0.1", false},
+		{"127.1.2.3", false},
+		{"::1", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"fd00::1", false},
+		{"169.254.169.254", false}, // cloud metadata
+		{"fe80::1", false},
+		{"0.0.0.0", false},
+		{"::", false},
+		{"100.64.0.1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.0.0.1", false},
+		{"64:ff9b::7f00:1", false}, // 127.0.0.1 through NAT64
+		{"64:ff9b::", false},
+	}
+
+	for _, test := range tests {
+		if got := IsPublicIP(net.ParseIP(test.ip)); got != test.public {
+			t.Errorf("IsPublicIP(%s) = %v, want %v", test.ip, got, test.public)
+		}
+	}
+}
+
+func TestSafeClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// the test server is on loopback
+	_, err := NewSafeClient(time.Second).Get(server.URL)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("got %v, want ErrPrivateAddress", err)
+	}
+}
+
+func TestSafeClientRefusesRedirectsToPrivateAddresses(t *testing.T) {
+	private := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer private.Close()
+	redirected := false
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+		http.Redirect(w, r, private.URL, http.StatusFound)
+	}))
+	defer public.Close()
+
+	// only the redirecting server counts as public
+	publicUrl, _ := url.Parse(public.URL)
+	client := newSafeClient(time.Second, func(host, port string) bool {
+		return net.JoinHostPort(host, port) == publicUrl.Host
+	})
+
+	_, err := client.Get(public.URL)
+	if !redirected {
+		t.Fatalf("the public server wasn't reached: %v", err)
+	}
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("got %v following a redirect to a private address, want ErrPrivateAddress", err)
+	}
+
+	// and the unfurler gives up on the page
+	if _, err := New(client).Unfurl(context.Background(), public.URL); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("got %v unfurling a redirect to a private address, want ErrPrivateAddress", err)
+	}
+}
+
+func TestSafeClientRefusesRedirectsToOtherSchemes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "ftp://example.com/file", http.StatusFound)
+	}))
+	defer server.Close()
+
+	client := newSafeClient(time.Second, func(host, port string) bool { return true })
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("followed a redirect to ftp")
+	}
+}
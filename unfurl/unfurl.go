@@ -0,0 +1,117 @@
+// Package unfurl fetches the title, description and image a web page gives for link previews,
+// from its Open Graph and Twitter card meta tags
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// how much of a page is read looking for its meta tags, they are in the head
+const maxPageSize = 512 * 1024
+
+// ErrNoPreview is returned for pages that don't have anything to show in a preview
+var ErrNoPreview = errors.New("the page has no title or description")
+
+// Card is what a page says about itself for link previews
+type Card struct {
+	// the url that was asked for
+	Url string
+	// twitter:card, e.g. summary or summary_large_image
+	Type        string
+	Title       string
+	Description string
+	// absolute url of the preview image
+	Image     string
+	Site_name string
+}
+
+// Unfurler fetches cards with the http client it is given
+type Unfurler struct {
+	client    *http.Client
+	userAgent string
+}
+
+// New creates an Unfurler that fetches pages with client
+// use NewSafeClient unless the pages are known to be safe to fetch, e.g. in tests
+func New(client *http.Client) *Unfurler {
+	return &Unfurler{client: client, userAgent: "Chirpy link previews"}
+}
+
+// Unfurl fetches a http or https url and reads its card
+// Open Graph tags win over Twitter card tags, which win over the page's <title> and description
+func (unfurler *Unfurler) Unfurl(ctx context.Context, rawUrl string) (Card, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return Card{}, fmt.Errorf("can't preview %q, only http and https urls", rawUrl)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return Card{}, err
+	}
+	req.Header.Set("User-Agent", unfurler.userAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := unfurler.client.Do(req)
+	if err != nil {
+		return Card{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Card{}, fmt.Errorf("fetching %s: %s", rawUrl, resp.Status)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Card{}, fmt.Errorf("fetching %s: not a web page but %q", rawUrl, mediaType)
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return Card{}, err
+	}
+
+	// relative image urls are relative to where the page ended up after redirects
+	card := parseCard(string(page), resp.Request.URL)
+	card.Url = rawUrl
+	if card.Title == "" && card.Description == "" {
+		return Card{}, ErrNoPreview
+	}
+	return card, nil
+}
+
+// reads a card from the meta tags of a page at base
+func parseCard(page string, base *url.URL) Card {
+	tags := metaTags(page)
+	first := func(names ...string) string {
+		for _, name := range names {
+			if value := strings.TrimSpace(tags[name]); value != "" {
+				return value
+			}
+		}
+		return ""
+	}
+
+	card := Card{
+		Type:        first("twitter:card"),
+		Title:       first("og:title", "twitter:title"),
+		Description: first("og:description", "twitter:description", "description"),
+		Site_name:   first("og:site_name"),
+	}
+	if card.Title == "" {
+		card.Title = pageTitle(page)
+	}
+	if image := first("og:image:secure_url", "og:image", "og:image:url", "twitter:image", "twitter:image:src"); image != "" {
+		if resolved, err := base.Parse(image); err == nil && (resolved.Scheme == "http" || resolved.Scheme == "https") {
+			card.Image = resolved.String()
+		}
+	}
+	return card
+}
@@ -0,0 +1,133 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestParseCard(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post?id=1")
+
+	tests := []struct {
+		name string
+		page string
+		want Card
+	}{
+		{
+			name: "open graph wins over twitter and the title",
+			page: `<html><head><title>Page title</title>
+				<meta name="twitter:title" content="Twitter title">
+				<meta property="og:title" content="OG title">
+				<meta name="twitter:description" content="Twitter description">
+				<meta property="og:description" content="OG description">
+				<meta name="description" content="Description">
+				<meta name="twitter:card" content="summary_large_image">
+				<meta property="og:site_name" content="Example">`,
+			want: Card{Type: "summary_large_image", Title: "OG title", Description: "OG description", Site_name: "Example"},
+		},
+		{
+			name: "twitter wins over the title and description",
+			page: `<title>Page title</title>
+				<meta name="description" content="Description">
+				<meta name="twitter:title" content="Twitter title">
+				<meta name="twitter:description" content="Twitter description">`,
+			want: Card{Title: "Twitter title", Description: "Twitter description"},
+		},
+		{
+			name: "the title and description when there are no cards",
+			page: `<title>
+				Page &amp; title
+			</title><meta name="description" content="Description">`,
+			want: Card{Title: "Page & title", Description: "Description"},
+		},
+		{
+			name: "empty tags are skipped",
+			page: `<title>Page title</title><meta property="og:title" content="  ">`,
+			want: Card{Title: "Page title"},
+		},
+		{
+			name: "the first of the same tag wins",
+			page: `<meta property="og:title" content="First"><meta property="og:title" content="Second">`,
+			want: Card{Title: "First"},
+		},
+		{
+			name: "relative images are resolved against the page",
+			page: `<meta property="og:title" content="OG title"><meta property="og:image" content="../images/cover.png">`,
+			want: Card{Title: "OG title", Image: "https://example.com/images/cover.png"},
+		},
+		{
+			name: "root relative images",
+			page: `<meta property="og:title" content="OG title"><meta name="twitter:image" content="/cover.png">`,
+			want: Card{Title: "OG title", Image: "https://example.com/cover.png"},
+		},
+		{
+			name: "the secure open graph image wins",
+			page: `<meta property="og:image" content="http://cdn.example.com/a.png">
+				<meta property="og:image:secure_url" content="https://cdn.example.com/a.png">`,
+			want: Card{Image: "https://cdn.example.com/a.png"},
+		},
+		{
+			name: "images that aren't http or https are dropped",
+			page: `<meta property="og:title" content="OG title"><meta property="og:image" content="javascript:alert(1)">`,
+			want: Card{Title: "OG title"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseCard(test.page, base); got != test.want {
+				t.Errorf("got %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUnfurl(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/posts/new", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/posts/new", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(`<meta property="og:title" content="New post"><meta property="og:image" content="cover.png">`))
+	})
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<p>nothing to see</p>`))
+	})
+	mux.HandleFunc("/image.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("not a page"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	unfurler := New(server.Client())
+
+	card, err := unfurler.Unfurl(context.Background(), server.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the card is for the url asked for, the image is relative to where the page ended up
+	want := Card{Url: server.URL + "/old", Title: "New post", Image: server.URL + "/posts/cover.png"}
+	if card != want {
+		t.Errorf("got %+v, want %+v", card, want)
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), server.URL+"/empty"); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("got %v for a page without a preview, want ErrNoPreview", err)
+	}
+	if _, err := unfurler.Unfurl(context.Background(), server.URL+"/image.png"); err == nil {
+		t.Error("an image was unfurled like a page")
+	}
+	if _, err := unfurler.Unfurl(context.Background(), server.URL+"/missing"); err == nil {
+		t.Error("a 404 was unfurled")
+	}
+	if _, err := unfurler.Unfurl(context.Background(), "file:///etc/passwd"); err == nil {
+		t.Error("a file url was unfurled")
+	}
+}
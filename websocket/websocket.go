@@ -0,0 +1,306 @@
+// Package websocket is a small server side implementation of the WebSocket protocol (RFC 6455)
+// just enough for Chirpy's live API: text messages, fragmentation, ping/pong and close
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// message and control frame opcodes
+const (
+	continuationMessage = 0
+	TextMessage         = 1
+	BinaryMessage       = 2
+	CloseMessage        = 8
+	PingMessage         = 9
+	PongMessage         = 10
+)
+
+// close status codes used by Chirpy
+const (
+	CloseNormal          = 1000
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseTryAgainLater   = 1013
+)
+
+// appended to the client's key to prove the server speaks WebSocket
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// ErrClosed is returned by ReadMessage once the client closed the connection,
+// and by writes once a close frame was sent
+var ErrClosed = errors.New("websocket: connection closed")
+
+// Conn is an upgraded WebSocket connection
+// ReadMessage must only be called from one goroutine, the write methods are safe to call from any
+type Conn struct {
+	conn           net.Conn
+	reader         *bufio.Reader
+	writeMux       *sync.Mutex
+	maxMessageSize int64
+	// how long ReadMessage waits for the next frame, 0 for no limit, see SetReadTimeout
+	readTimeout time.Duration
+	// only one close frame is sent and nothing after it, guarded by writeMux
+	closeSent bool
+}
+
+// Upgrade switches an http request to the WebSocket protocol
+// messages bigger than maxMessageSize bytes are rejected
+// on failure an error response has already been written
+func Upgrade(w http.ResponseWriter, r *http.Request, maxMessageSize int64) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "expected a websocket upgrade request", http.StatusBadRequest)
+		return nil, errors.New("websocket: not an upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websockets are not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response can't be hijacked")
+	}
+	conn, buffered, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(hash[:]) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &Conn{
+		conn:           conn,
+		reader:         buffered.Reader,
+		writeMux:       &sync.Mutex{},
+		maxMessageSize: maxMessageSize,
+	}, nil
+}
+
+// ReadMessage returns the next text or binary message from the client
+// pings are answered and pongs skipped along the way
+// returns ErrClosed when the client closes the connection
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	message := []byte{}
+
+	for {
+		// any frame shows the client is still there, pongs included
+		if c.readTimeout > 0 {
+			c.conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+		}
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			// echo the status code back, as the protocol asks
+			c.writeFrame(CloseMessage, payload)
+			return 0, nil, ErrClosed
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(ClosePolicyViolation, "new message before the last one finished")
+			}
+			messageType = opcode
+		case continuationMessage:
+			if messageType == 0 {
+				return 0, nil, c.fail(ClosePolicyViolation, "continuation without a message")
+			}
+		default:
+			return 0, nil, c.fail(ClosePolicyViolation, "unknown opcode")
+		}
+
+		if int64(len(message)+len(payload)) > c.maxMessageSize {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+		if fin {
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage sends a single frame message to the client
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	return c.writeFrame(messageType, data)
+}
+
+// WriteClose sends a close frame with a status code and reason, then closes the connection
+// if a close frame was already sent, e.g. echoing the client's, it only closes the connection
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	payload = append(payload, reason...)
+	err := c.writeFrame(CloseMessage, payload)
+	if errors.Is(err, ErrClosed) {
+		err = nil
+	}
+	c.conn.Close()
+	return err
+}
+
+// SetReadDeadline sets how long ReadMessage can wait for the client
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetReadTimeout makes ReadMessage give up when the client sends no frame at all for timeout,
+// the deadline moves with every frame, so clients that only answer pings stay connected
+// replaces SetReadDeadline, 0 turns it off
+func (c *Conn) SetReadTimeout(timeout time.Duration) {
+	c.readTimeout = timeout
+}
+
+// SetWriteDeadline sets how long writes can wait for the client
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// Close closes the connection without a close frame
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// reads one frame, unmasking its payload
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, header); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := int(header[0] & 0x0f)
+	masked := header[1]&0x80 != 0
+	length := int64(header[1] & 0x7f)
+
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, c.fail(ClosePolicyViolation, "unsupported extension bits")
+	}
+	// clients always have to mask their frames
+	if !masked {
+		return false, 0, nil, c.fail(ClosePolicyViolation, "unmasked frame")
+	}
+
+	switch length {
+	case 126:
+		extended := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(extended))
+	case 127:
+		extended := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, extended); err != nil {
+			return false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(extended))
+	}
+
+	isControl := opcode >= CloseMessage
+	if isControl && (length > 125 || !fin) {
+		return false, 0, nil, c.fail(ClosePolicyViolation, "invalid control frame")
+	}
+	if length < 0 || length > c.maxMessageSize {
+		return false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	mask := make([]byte, 4)
+	if _, err := io.ReadFull(c.reader, mask); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writes one unmasked, final frame
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	c.writeMux.Lock()
+	defer c.writeMux.Unlock()
+
+	if c.closeSent {
+		return ErrClosed
+	}
+	if opcode == CloseMessage {
+		c.closeSent = true
+	}
+
+	header := []byte{0x80 | byte(opcode)}
+	switch {
+	case len(payload) <= 125:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xffff:
+		header = append(header, 126, 0, 0)
+		binary.BigEndian.PutUint16(header[2:], uint16(len(payload)))
+	default:
+		header = append(header, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(header[2:], uint64(len(payload)))
+	}
+
+	if _, err := c.conn.Write(header); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// closes the connection because the client broke the protocol
+func (c *Conn) fail(code int, reason string) error {
+	c.WriteClose(code, reason)
+	return fmt.Errorf("websocket: %s", reason)
+}
+
+// checks a comma separated header for a token, ignoring case
+func headerContainsToken(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}